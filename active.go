@@ -0,0 +1,69 @@
+package gls
+
+// RangeActive calls fn for every goroutine that currently has values on this
+// ContextManager, with the goroutine's identifier and its value for the
+// Option.PrimaryKey the ContextManager was created with, or nil if it has none
+// (or the key's value was set by SetLazy and has not been computed yet). This
+// lists, say, every request in flight by id without copying whole contexts.
+// fn returns false to stop early.
+//
+// RangeActive is a diagnostic. It reads the key from every goroutine at once,
+// blocking SetValues calls on this ContextManager while it does so, and then
+// calls fn without holding any locks. What fn sees is a point-in-time
+// snapshot: by the time it is called, goroutines may have finished or
+// started, and their values may have changed.
+func (m *ContextManager) RangeActive(fn func(gid uint32, primary interface{}) bool) {
+	type active struct {
+		gid     uint32
+		primary interface{}
+	}
+	var actives []active
+
+	key := m.normalizeKey(m.primaryKey)
+	m.extendLock.Lock()
+	for gid, state := range m.values {
+		if len(state) == 0 {
+			continue
+		}
+		var primary interface{}
+		if m.primaryKey != nil {
+			primary = state[key]
+		}
+		actives = append(actives, active{gid: uint32(gid), primary: primary})
+	}
+	m.extendLock.Unlock()
+
+	for _, a := range actives {
+		if !fn(a.gid, peekValue(a.primary)) {
+			return
+		}
+	}
+}
+
+// AllKeysSeen returns every key set on this ContextManager on any goroutine,
+// each once, ordered as SortedKeys orders them by default, for auditing the
+// shape of the context a subsystem uses. Like RangeActive, it is a diagnostic
+// and a point-in-time snapshot: it walks every goroutine's values at once,
+// blocking SetValues calls on this ContextManager while it does so, and keys
+// no goroutine has set at that moment are not included, however recently
+// they were.
+func (m *ContextManager) AllKeysSeen() []interface{} {
+	seen := make(map[interface{}]bool)
+	m.extendLock.Lock()
+	for _, state := range m.values {
+		for key := range state {
+			seen[key] = true
+		}
+	}
+	m.extendLock.Unlock()
+
+	if len(seen) == 0 {
+		return nil
+	}
+	keys := make([]interface{}, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sortKeys(keys, nil)
+	return keys
+}
@@ -0,0 +1,84 @@
+package gls
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRangeActive(t *testing.T) {
+	mgr := NewContextManager(Option{PrimaryKey: RequestIDKey})
+	defer mgr.Unregister()
+
+	var started, finish sync.WaitGroup
+	finish.Add(1)
+	ids := []string{"a", "b", "c"}
+	for _, id := range ids {
+		started.Add(1)
+		go SetRequestID(mgr, id, func() {
+			started.Done()
+			finish.Wait()
+		})
+	}
+	started.Add(1)
+	go mgr.SetValues(Values{"other": 1}, func() {
+		started.Done()
+		finish.Wait()
+	})
+	started.Wait()
+
+	seen := make(map[interface{}]int)
+	mgr.RangeActive(func(gid uint32, primary interface{}) bool {
+		seen[primary]++
+		return true
+	})
+	for _, id := range ids {
+		if seen[id] != 1 {
+			t.Fatalf("expected request %s to be listed once, got %v", id, seen)
+		}
+	}
+	if seen[nil] != 1 || len(seen) != 4 {
+		t.Fatalf("expected one goroutine without a request id, got %v", seen)
+	}
+
+	var calls int
+	mgr.RangeActive(func(gid uint32, primary interface{}) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Fatalf("expected RangeActive to stop early, got %d calls", calls)
+	}
+
+	finish.Done()
+}
+
+func TestAllKeysSeen(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if keys := mgr.AllKeysSeen(); keys != nil {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+
+	var started, finish sync.WaitGroup
+	finish.Add(1)
+	for _, values := range []Values{
+		{"request": 1, "user": "a"},
+		{"request": 2, "tenant": "t"},
+		{"request": 3},
+	} {
+		started.Add(1)
+		go mgr.SetValues(values, func() {
+			started.Done()
+			finish.Wait()
+		})
+	}
+	started.Wait()
+
+	keys := mgr.AllKeysSeen()
+	finish.Done()
+	if fmt.Sprint(keys) != "[request tenant user]" {
+		t.Fatalf("expected [request tenant user], got %v", keys)
+	}
+}
@@ -0,0 +1,60 @@
+package gls
+
+// Cloner is implemented by values that can copy themselves. A value set on a
+// ContextManager that implements Cloner is copied on write: goroutines that
+// inherit it, through Go, Wrap or a Token, share it for reading, and get
+// their own copy the first time they call GetValueForWrite for its key.
+// SetPropagationPolicy can have it copied eagerly, or shared outright,
+// instead.
+type Cloner interface {
+	// Clone returns a copy of the receiver that can be modified without
+	// affecting it.
+	Clone() interface{}
+}
+
+// sharedValue marks a Cloner value inherited from another goroutine, which
+// must be cloned before it is modified.
+type sharedValue struct {
+	value interface{}
+}
+
+// GetValueForWrite is like GetValue, but returns a value the current
+// goroutine may modify in place. A value inherited from another goroutine
+// that implements Cloner is cloned on the first call, and the clone replaces
+// it for the rest of the current goroutine's scope, so the goroutine that set
+// it, and any siblings sharing it, never see the modification. Later calls,
+// and GetValue, return the clone. Values that don't implement Cloner, or that
+// were set on the current goroutine, are returned as is, exactly as GetValue
+// would.
+//
+// Modifying a value obtained from GetValue, instead of GetValueForWrite, is
+// never safe if the value may have been inherited.
+func (m *ContextManager) GetValueForWrite(key interface{}) (
+	value interface{}, ok bool) {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return m.missing(key)
+	}
+	state := m.state(gid)
+	if state == nil {
+		return m.missing(key)
+	}
+	stored := m.normalizeKey(key)
+	value, ok = state[stored]
+	if !ok {
+		return m.missing(key)
+	}
+	shared, isShared := value.(*sharedValue)
+	if !isShared {
+		if value, ok = m.resolve(state, stored, value); !ok {
+			return m.missing(key)
+		}
+		return value, true
+	}
+	value = shared.value.(Cloner).Clone()
+	m.extendLock.RLock()
+	state[stored] = value
+	m.bumpGeneration(gid)
+	m.extendLock.RUnlock()
+	return value, true
+}
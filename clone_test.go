@@ -0,0 +1,67 @@
+package gls
+
+import (
+	"testing"
+)
+
+type counters map[string]int
+
+func (c counters) Clone() interface{} {
+	clone := make(counters, len(c))
+	for k, v := range c {
+		clone[k] = v
+	}
+	return clone
+}
+
+func TestGetValueForWrite(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"counters": counters{"hits": 1}, "plain": 1}, func() {
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			if val, _ := mgr.GetValue("counters"); val.(counters)["hits"] != 1 {
+				t.Errorf("expected inherited value to be readable, got %v", val)
+			}
+			val, _ := mgr.GetValueForWrite("counters")
+			val.(counters)["hits"]++
+			if val, _ := mgr.GetValue("counters"); val.(counters)["hits"] != 2 {
+				t.Errorf("expected child to see its own write, got %v", val)
+			}
+			again, _ := mgr.GetValueForWrite("counters")
+			again.(counters)["hits"]++
+			if val.(counters)["hits"] != 3 {
+				t.Errorf("expected a single clone per goroutine, got %v", val)
+			}
+			if val, _ := mgr.GetValueForWrite("plain"); val != 1 {
+				t.Errorf("expected non-Cloner value as is, got %v", val)
+			}
+		})
+		<-done
+
+		val, _ := mgr.GetValue("counters")
+		if val.(counters)["hits"] != 1 {
+			t.Fatalf("expected parent to be unaffected by child's write, got %v", val)
+		}
+		own, _ := mgr.GetValueForWrite("counters")
+		own.(counters)["hits"]++
+		if val.(counters)["hits"] != 2 {
+			t.Fatalf("expected values set on this goroutine not to be cloned")
+		}
+	})
+}
+
+func TestSnapshotUnwrapsShared(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"counters": counters{"a": 1}}, func() {
+		done := make(chan Values)
+		Go(func() { done <- mgr.Snapshot() })
+		if _, ok := (<-done)["counters"].(counters); !ok {
+			t.Fatalf("expected the child's Snapshot to hold counters")
+		}
+	})
+}
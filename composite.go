@@ -0,0 +1,77 @@
+package gls
+
+// Composite presents several ContextManagers, such as separate ones for
+// logging, auth and tracing, as one, so application code doesn't have to pick
+// the right one at every call site.
+//
+// GetValue searches the managers in order and returns the first value found,
+// so earlier managers take precedence, including through their defaults.
+// SetValues sends each key to the manager it was routed to with Route, and
+// any key without a route to the first manager.
+type Composite struct {
+	mgrs   []*ContextManager
+	routes map[interface{}]*ContextManager
+}
+
+// NewComposite returns a Composite over mgrs, in lookup order.
+func NewComposite(mgrs ...*ContextManager) *Composite {
+	return &Composite{
+		mgrs:   append([]*ContextManager(nil), mgrs...),
+		routes: make(map[interface{}]*ContextManager),
+	}
+}
+
+// Route makes SetValues store key on mgr, adding mgr to the end of the lookup
+// order if it isn't already part of c. Routes are meant to be set up before c
+// is used, and must not be changed while any goroutine uses c. Route returns c
+// to allow chaining.
+func (c *Composite) Route(key interface{}, mgr *ContextManager) *Composite {
+	c.routes[key] = mgr
+	for _, existing := range c.mgrs {
+		if existing == mgr {
+			return c
+		}
+	}
+	c.mgrs = append(c.mgrs, mgr)
+	return c
+}
+
+// GetValue returns the value for key from the first of c's managers that has
+// one, as GetFirst does.
+func (c *Composite) GetValue(key interface{}) (value interface{}, ok bool) {
+	return GetFirst(key, c.mgrs...)
+}
+
+// SetValues splits values between c's managers according to their routes and
+// calls context_call with all of them set, each manager's share restored
+// when context_call returns, as with SetValues. It panics if c has no
+// managers and values is not empty.
+func (c *Composite) SetValues(values Values, context_call func()) {
+	if len(values) == 0 {
+		context_call()
+		return
+	}
+	if len(c.mgrs) == 0 {
+		panic("gls: SetValues on a Composite with no ContextManagers")
+	}
+	shares := make(map[*ContextManager]Values)
+	for key, val := range values {
+		mgr := c.routes[key]
+		if mgr == nil {
+			mgr = c.mgrs[0]
+		}
+		if shares[mgr] == nil {
+			shares[mgr] = make(Values)
+		}
+		shares[mgr][key] = val
+	}
+
+	call := context_call
+	for _, mgr := range c.mgrs {
+		if share := shares[mgr]; share != nil {
+			mgr, inner := mgr, call
+			call = func() { mgr.SetValues(share, inner) }
+		}
+	}
+	call()
+}
@@ -0,0 +1,60 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestComposite(t *testing.T) {
+	logging := NewContextManager(Option{})
+	defer logging.Unregister()
+	auth := NewContextManager(Option{})
+	defer auth.Unregister()
+
+	c := NewComposite(logging, auth).Route("user", auth)
+
+	logging.SetValues(Values{"level": "debug", "user": "shadowed"}, func() {
+		auth.SetValues(Values{"user": "bob", "role": "admin"}, func() {
+			for key, exp := range map[string]string{
+				"level": "debug", "role": "admin", "user": "shadowed"} {
+				if val, _ := c.GetValue(key); val != exp {
+					t.Fatalf("expected %s for %s, got %v", exp, key, val)
+				}
+			}
+		})
+	})
+	if _, ok := c.GetValue("level"); ok {
+		t.Fatalf("expected no value outside the scopes")
+	}
+
+	c.SetValues(Values{"user": "alice", "request": "1"}, func() {
+		if val, _ := auth.GetValue("user"); val != "alice" {
+			t.Fatalf("expected user to be routed to auth, got %v", val)
+		}
+		if _, ok := logging.GetValue("user"); ok {
+			t.Fatalf("expected user not to be set on logging")
+		}
+		if val, _ := logging.GetValue("request"); val != "1" {
+			t.Fatalf("expected unrouted request on logging, got %v", val)
+		}
+		if _, ok := auth.GetValue("request"); ok {
+			t.Fatalf("expected request not to be set on auth")
+		}
+	})
+	if _, ok := auth.GetValue("user"); ok {
+		t.Fatalf("expected routed values to be restored")
+	}
+}
+
+func TestCompositeRouteAddsManager(t *testing.T) {
+	first := NewContextManager(Option{})
+	defer first.Unregister()
+	extra := NewContextManager(Option{})
+	defer extra.Unregister()
+
+	c := NewComposite(first).Route("trace", extra)
+	c.SetValues(Values{"trace": "t1"}, func() {
+		if val, _ := c.GetValue("trace"); val != "t1" {
+			t.Fatalf("expected t1 from the routed manager, got %v", val)
+		}
+	})
+}
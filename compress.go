@@ -0,0 +1,89 @@
+package gls
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// Codec compresses values set with SetCompressed. Its methods must be safe
+// for concurrent use.
+type Codec interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCodec is the Codec SetCompressed uses if the ContextManager's
+// Option.Codec is nil.
+var GzipCodec Codec = gzipCodec{}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+type compressedValue struct {
+	data  []byte
+	codec Codec
+}
+
+// bytes returns v's data decompressed. A failure is logged, and ok is false.
+func (v *compressedValue) bytes() (data []byte, ok bool) {
+	data, err := v.codec.Decompress(v.data)
+	if err != nil {
+		logf("gls: decompressing a value set with SetCompressed: %v", err)
+		return nil, false
+	}
+	return data, true
+}
+
+// SetCompressed is like SetValues with a single key, except that data is
+// kept compressed with the ContextManager's Option.Codec while fn runs, for
+// large values, such as serialized headers, that sit in memory for a whole
+// request but are rarely read. Every read decompresses data again, trading
+// CPU for memory, so frequently read values should be set with SetValues
+// instead. GetCompressed, GetValue and the like all return the decompressed
+// bytes, as a new slice on every read. If they can't be decompressed, the
+// error is logged, through the function set with SetLogf, and the key reads
+// as not set. SetCompressed panics if data can't be compressed.
+func (m *ContextManager) SetCompressed(key interface{}, data []byte, fn func()) {
+	codec := m.codec
+	if codec == nil {
+		codec = GzipCodec
+	}
+	compressed, err := codec.Compress(data)
+	if err != nil {
+		panic(err)
+	}
+	m.SetValues(Values{key: &compressedValue{data: compressed, codec: codec}}, fn)
+}
+
+// GetCompressed returns the bytes set for key, decompressed if they were set
+// with SetCompressed. ok is false if key isn't set, isn't a []byte, or can't
+// be decompressed, which is logged as for GetValue.
+func (m *ContextManager) GetCompressed(key interface{}) (data []byte, ok bool) {
+	value, found := m.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	data, ok = value.([]byte)
+	return data, ok
+}
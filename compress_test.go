@@ -0,0 +1,105 @@
+package gls
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetCompressed(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	data := []byte(strings.Repeat("X-Forwarded-For: 10.0.0.1\r\n", 200))
+	mgr.SetCompressed("headers", data, func() {
+		got, ok := mgr.GetCompressed("headers")
+		if !ok || !bytes.Equal(got, data) {
+			t.Fatalf("expected the data back, got %d bytes", len(got))
+		}
+		if val, _ := mgr.GetValue("headers"); !bytes.Equal(val.([]byte), data) {
+			t.Fatalf("expected GetValue to decompress too")
+		}
+
+		gid, _ := GetGoroutineId()
+		stored, _ := mgr.state(gid)["headers"].(*compressedValue)
+		if stored == nil || len(stored.data) >= len(data) {
+			t.Fatalf("expected the stored form to be smaller than %d bytes",
+				len(data))
+		}
+
+		done := make(chan []byte)
+		Go(func() {
+			got, _ := mgr.GetCompressed("headers")
+			done <- got
+		})
+		if got := <-done; !bytes.Equal(got, data) {
+			t.Fatalf("expected the data in a child goroutine")
+		}
+	})
+
+	mgr.SetValues(Values{"plain": []byte("raw"), "other": 1}, func() {
+		if got, ok := mgr.GetCompressed("plain"); !ok || string(got) != "raw" {
+			t.Fatalf("expected uncompressed bytes as is, got %q", got)
+		}
+		if _, ok := mgr.GetCompressed("other"); ok {
+			t.Fatalf("expected a non-[]byte value to be reported missing")
+		}
+	})
+}
+
+type reverseCodec struct{}
+
+func (reverseCodec) Compress(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return out, nil
+}
+
+func (c reverseCodec) Decompress(data []byte) ([]byte, error) {
+	return c.Compress(data)
+}
+
+func TestSetCompressedCodec(t *testing.T) {
+	mgr := NewContextManager(Option{Codec: reverseCodec{}})
+	defer mgr.Unregister()
+
+	mgr.SetCompressed("key", []byte("abc"), func() {
+		gid, _ := GetGoroutineId()
+		stored := mgr.state(gid)["key"].(*compressedValue)
+		if string(stored.data) != "cba" {
+			t.Fatalf("expected the configured codec to be used, got %q",
+				stored.data)
+		}
+		if got, _ := mgr.GetCompressed("key"); string(got) != "abc" {
+			t.Fatalf("expected abc, got %q", got)
+		}
+	})
+}
+
+type brokenCodec struct{ reverseCodec }
+
+func (brokenCodec) Decompress(data []byte) ([]byte, error) {
+	return nil, errors.New("corrupt")
+}
+
+func TestSetCompressedDecodeFailure(t *testing.T) {
+	mgr := NewContextManager(Option{Codec: brokenCodec{}})
+	defer mgr.Unregister()
+
+	stop := captureLogs()
+	mgr.SetCompressed("key", []byte("abc"), func() {
+		if val, ok := mgr.GetValue("key"); ok {
+			t.Fatalf("expected an undecodable value to read as not set, got %v", val)
+		}
+		if _, ok := mgr.GetCompressed("key"); ok {
+			t.Fatalf("expected GetCompressed to fail")
+		}
+	})
+	logs := stop()
+	if len(logs) != 2 || !strings.Contains(logs[0], "corrupt") {
+		t.Fatalf("expected the failures to be logged, got %q", logs)
+	}
+}
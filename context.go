@@ -2,7 +2,13 @@
 package gls
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -13,6 +19,20 @@ const (
 var (
 	mgrRegistry    = make(map[*ContextManager]bool)
 	mgrRegistryMtx sync.RWMutex
+
+	// goroutineFlags holds package-internal goroutine-local settings. It is
+	// deliberately kept out of mgrRegistry so Go never propagates it.
+	goroutineFlags = newContextManager(Option{})
+
+	// DefaultManager is a ContextManager for helpers, such as WithValue,
+	// that need one without being told which. It registers itself, and so
+	// starts being propagated by Go, the first time values are set on it,
+	// so that programs never using it don't pay for it on every Go call.
+	DefaultManager = newDefaultManager()
+
+	// ErrInvalidOption is wrapped by the errors NewContextManagerE returns
+	// for an invalid Option.
+	ErrInvalidOption = errors.New("gls: invalid Option")
 )
 
 // Values is simply a map of key types to value types. Used by SetValues to
@@ -25,42 +45,220 @@ type Values map[interface{}]interface{}
 // class of context variables. You should use NewContextManager for
 // construction.
 type ContextManager struct {
+	// extendLock is write-locked to extend values and meta, and to walk every
+	// goroutine's values at once. A goroutine changing its own entry, or the
+	// map in it, holds a read lock, as no two goroutines share an entry.
 	extendLock               sync.RWMutex
 	extendUnit               uint32
 	values                   []Values
+	meta                     []*goroutineMeta
+	generations              []uint64
+	depths                   []uint32 // nested SetValues scopes per goroutine
 	currentMaxGoroutineCount int
+	pinMode                  PinMode
+	keyNormalizer            func(key interface{}) interface{}
+	debug                    bool
+	panicHandler             func(recovered interface{}, v Values)
+	primaryKey               interface{}
+	scopeTimer               func(keys []interface{}, took time.Duration)
+	name                     string // set by GetOrCreateManager
+	codec                    Codec
+	childIDDerive            func(parent interface{}) interface{}
+	childIDKey               interface{}
+	onFirstUse               atomic.Value // func(gid uint32)
+	onRelease                atomic.Value // func(gid uint32)
+	defaults                 atomic.Value // Values
+	policies                 atomic.Value // map[interface{}]Policy
+	policiesMtx              sync.Mutex   // serializes policies updates
+	registerOnUse            int32        // set for DefaultManager until used
 }
 
+// Option configures a ContextManager. The zero Option is valid, and
+// NewContextManagerE describes what isn't.
 type Option struct {
+	// InitialMaxGoroutineCount is how many goroutine identifiers the
+	// ContextManager has room for before it first grows. It must be between
+	// 0 and math.MaxUint32, with 0 meaning 1024.
 	InitialMaxGoroutineCount int
-	ExtendUnit               int
+	// ExtendUnit is how many goroutine identifiers the ContextManager makes
+	// room for at a time once it needs more. It must be between 0 and
+	// math.MaxUint32, with 0 meaning 128.
+	ExtendUnit int
+	// PinMode controls what SetValues does when asked to shadow a key set by
+	// Pin. It must be PinIgnore, the zero value, or PinPanic.
+	PinMode PinMode
+	// KeyNormalizer, if set, is applied to every key before it is stored or
+	// looked up, so keys that normalize to the same value share a slot, e.g.
+	// lowercasing string keys makes GetValue("ID") and GetValue("id")
+	// equivalent. It should leave keys it doesn't understand unchanged. It
+	// costs a call per key on every SetValues and GetValue, plus a copy of
+	// the map passed to SetValues.
+	KeyNormalizer func(key interface{}) interface{}
+	// Debug turns on development aids: the SetValues history kept for
+	// History, warnings about Values maps modified after being passed to
+	// SetValues, warnings about keys a SetValues scope set but that
+	// GetValue never read while it ran, and warnings about goroutines started
+	// by Go that only begin running once the scope they inherit values from
+	// has exited. They cost stack walks and a copy of the map per SetValues,
+	// and a counter update per GetValue, so Debug should not be set in
+	// production.
+	Debug bool
+	// PanicHandler, if set, is called when a panic unwinds through a
+	// SetValues scope, with the panic value and a copy of the values that
+	// were set at the time, for instance to attach request context to a
+	// crash report. See SetValues for details.
+	PanicHandler func(recovered interface{}, v Values)
+	// PrimaryKey is the key RangeActive reports for each goroutine, such as
+	// the key holding a request id. Like any key, it must be comparable.
+	PrimaryKey interface{}
+	// ScopeTimer, if set, is called as each SetValuesTimed scope exits, with
+	// the keys the scope set, in SortedKeys' default order, and how long its
+	// callback ran.
+	ScopeTimer func(keys []interface{}, took time.Duration)
+	// Codec compresses values set with SetCompressed. Nil means GzipCodec.
+	Codec Codec
+	// ChildIDDerive and ChildIDKey, which must be set together, give
+	// goroutines started with Go their own id derived from their parent's,
+	// such as a child span id: wherever propagation policies apply, that is
+	// in Go, Wrap, Capture, Attach and the like, the value of ChildIDKey
+	// handed on is replaced with what ChildIDDerive returns for it. Nothing
+	// is derived if the parent has no value for ChildIDKey. A value set by
+	// SetLazy and not computed yet is passed as nil.
+	ChildIDDerive func(parent interface{}) interface{}
+	ChildIDKey    interface{}
 }
 
+// PinMode determines how a ContextManager enforces values set by Pin.
+type PinMode int
+
+const (
+	// PinIgnore makes SetValues silently skip pinned keys while still
+	// setting any other keys it was given.
+	PinIgnore PinMode = iota
+	// PinPanic makes SetValues panic when given a pinned key.
+	PinPanic
+)
+
 // NewContextManager returns a brand new ContextManager. It also registers the
 // new ContextManager in the ContextManager registry which is used by the Go
 // method. ContextManagers are typically defined globally at package scope.
+// NewContextManager panics if option is invalid; see NewContextManagerE.
 func NewContextManager(option Option) *ContextManager {
+	mgr, err := NewContextManagerE(option)
+	if err != nil {
+		panic(err)
+	}
+	return mgr
+}
+
+// NewContextManagerE is like NewContextManager, but returns an error wrapping
+// ErrInvalidOption instead of panicking if a field of option is out of the
+// range its documentation gives, so that misconfiguration is caught at
+// construction rather than showing up later as odd behavior.
+func NewContextManagerE(option Option) (*ContextManager, error) {
+	if err := option.validate(); err != nil {
+		return nil, err
+	}
+	mgr := newContextManager(option)
+	mgrRegistryMtx.Lock()
+	defer mgrRegistryMtx.Unlock()
+	mgrRegistry[mgr] = true
+	atomic.AddUint64(&managersRegistered, 1)
+	return mgr, nil
+}
+
+// newDefaultManager returns a ContextManager that registers itself the first
+// time values are set on it.
+func newDefaultManager() *ContextManager {
+	mgr := newContextManager(Option{})
+	mgr.registerOnUse = 1
+	return mgr
+}
+
+// registerIfUnused registers m if it was created by newDefaultManager and has
+// not been registered yet.
+func (m *ContextManager) registerIfUnused() {
+	if atomic.LoadInt32(&m.registerOnUse) == 0 {
+		return
+	}
+	mgrRegistryMtx.Lock()
+	defer mgrRegistryMtx.Unlock()
+	if atomic.LoadInt32(&m.registerOnUse) == 0 {
+		return
+	}
+	atomic.StoreInt32(&m.registerOnUse, 0)
+	mgrRegistry[m] = true
+	atomic.AddUint64(&managersRegistered, 1)
+}
+
+func (o Option) validate() error {
+	if o.InitialMaxGoroutineCount < 0 ||
+		uint64(o.InitialMaxGoroutineCount) > math.MaxUint32 {
+		return fmt.Errorf("%w: InitialMaxGoroutineCount %d is out of range",
+			ErrInvalidOption, o.InitialMaxGoroutineCount)
+	}
+	if o.ExtendUnit < 0 || uint64(o.ExtendUnit) > math.MaxUint32 {
+		return fmt.Errorf("%w: ExtendUnit %d is out of range",
+			ErrInvalidOption, o.ExtendUnit)
+	}
+	if o.PinMode != PinIgnore && o.PinMode != PinPanic {
+		return fmt.Errorf("%w: unknown PinMode %d", ErrInvalidOption, o.PinMode)
+	}
+	if o.PrimaryKey != nil && !reflect.TypeOf(o.PrimaryKey).Comparable() {
+		return fmt.Errorf("%w: PrimaryKey of type %T is not comparable",
+			ErrInvalidOption, o.PrimaryKey)
+	}
+	if (o.ChildIDDerive == nil) != (o.ChildIDKey == nil) {
+		return fmt.Errorf("%w: ChildIDDerive and ChildIDKey must be set "+
+			"together", ErrInvalidOption)
+	}
+	if o.ChildIDKey != nil && !reflect.TypeOf(o.ChildIDKey).Comparable() {
+		return fmt.Errorf("%w: ChildIDKey of type %T is not comparable",
+			ErrInvalidOption, o.ChildIDKey)
+	}
+	return nil
+}
+
+func newContextManager(option Option) *ContextManager {
 	if option.InitialMaxGoroutineCount == 0 {
 		option.InitialMaxGoroutineCount = initialMaxGoroutineCount
 	}
 	if option.ExtendUnit == 0 {
 		option.ExtendUnit = extendUnit
 	}
-	mgr := &ContextManager{values: make([]Values, option.InitialMaxGoroutineCount)}
+	mgr := &ContextManager{
+		values:      make([]Values, option.InitialMaxGoroutineCount),
+		meta:        make([]*goroutineMeta, option.InitialMaxGoroutineCount),
+		generations: make([]uint64, option.InitialMaxGoroutineCount),
+		depths:      make([]uint32, option.InitialMaxGoroutineCount),
+	}
 	mgr.currentMaxGoroutineCount = len(mgr.values)
 	mgr.extendUnit = uint32(option.ExtendUnit)
-	mgrRegistryMtx.Lock()
-	defer mgrRegistryMtx.Unlock()
-	mgrRegistry[mgr] = true
+	mgr.pinMode = option.PinMode
+	mgr.keyNormalizer = option.KeyNormalizer
+	mgr.debug = option.Debug
+	mgr.panicHandler = option.PanicHandler
+	mgr.primaryKey = option.PrimaryKey
+	mgr.scopeTimer = option.ScopeTimer
+	mgr.codec = option.Codec
+	mgr.childIDDerive = option.ChildIDDerive
+	if option.ChildIDKey != nil {
+		mgr.childIDKey = mgr.normalizeKey(option.ChildIDKey)
+	}
 	return mgr
 }
 
 // Unregister removes a ContextManager from the global registry, used by the
 // Go method. Only intended for use when you're completely done with a
-// ContextManager. Use of Unregister at all is rare.
+// ContextManager. Use of Unregister at all is rare. It is safe to call while
+// other goroutines are calling Go: each Go call either sees the
+// ContextManager, and propagates a complete copy of its values, or doesn't
+// see it at all.
 func (m *ContextManager) Unregister() {
+	m.forgetName()
 	mgrRegistryMtx.Lock()
 	defer mgrRegistryMtx.Unlock()
+	atomic.StoreInt32(&m.registerOnUse, 0)
 	delete(mgrRegistry, m)
 }
 
@@ -71,79 +269,389 @@ func (m *ContextManager) Unregister() {
 // previous stack frames.
 // SetValues is slow (makes a copy of all current and new values for the new
 // gls-context) in order to reduce the amount of lookups GetValue requires.
+// SetValues panics with ErrIDExhausted if the current goroutine needs an
+// identifier and none are available; see SetValuesE. A nil or empty
+// new_values sets nothing: context_call is simply called, and no identifier
+// is needed. The entries of new_values are copied, so changing the map after
+// passing it to SetValues, even while context_call runs, has no effect on the
+// values set; with Option.Debug, such changes are reported through the
+// function set with SetLogf.
+//
+// If the ContextManager has an Option.PanicHandler, a panic unwinding out of
+// context_call is recovered, reported to the handler along with a copy of the
+// values set at the time, and then re-raised with the same value once the
+// values are restored. A panic unwinding through several nested scopes is
+// reported once, by the innermost one. As the panic is re-raised, the stack
+// trace printed if nothing recovers it shows where SetValues re-raised it
+// rather than where it was first raised.
 func (m *ContextManager) SetValues(new_values Values, context_call func()) {
+	if err := m.SetValuesE(new_values, context_call); err != nil {
+		panic(err)
+	}
+}
+
+// SetValuesE is like SetValues, but returns ErrIDExhausted instead of
+// panicking when the current goroutine needs an identifier and none are
+// available, in which case context_call is not called. A nil or empty
+// new_values never fails, as it needs no identifier.
+func (m *ContextManager) SetValuesE(new_values Values, context_call func()) error {
 	if len(new_values) == 0 {
 		context_call()
-		return
+		return nil
 	}
 
-	mutated_keys := make([]interface{}, 0, len(new_values))
-	mutated_vals := make(Values, len(new_values))
+	atomic.AddUint64(&setValuesCalls, 1)
+	passed := new_values
+	new_values = m.normalizeValues(new_values)
 
-	EnsureGoroutineId(func(gid uint32) {
-		var found bool
+	return ensureGoroutineId(func(gid uint32) {
+		if flags := goroutineFlags.state(gid); flags != nil {
+			if _, frozen := flags[frozenKey{mgr: m}]; frozen {
+				panic("gls: SetValues called within Freeze")
+			}
+			new_values = m.unpinned(flags, new_values)
+		}
+
+		var (
+			found bool
+			// what to undo on exit, kept on the stack for the common case
+			// of a handful of keys. Keys that were not set before only need
+			// deleting; shadowed stays nil unless an existing value is
+			// overwritten.
+			added_buf    [8]interface{}
+			added        []interface{}
+			shadowed_buf [8]restoreEntry
+			shadowed     []restoreEntry
+		)
 		m.extendIfNeeded(gid)
 
+		m.extendLock.RLock()
 		state := m.values[gid]
 		if state != nil {
 			found = true
+			added = added_buf[:0]
+			for key, new_val := range new_values {
+				if old_val, existed := state[key]; existed {
+					if shadowed == nil {
+						shadowed = shadowed_buf[:0]
+					}
+					shadowed = append(shadowed, restoreEntry{
+						key: key, value: old_val})
+				} else {
+					added = append(added, key)
+				}
+				state[key] = new_val
+			}
 		} else {
+			// the whole entry is released on exit, so there is nothing to
+			// remember for the restore
 			state = make(Values, len(new_values))
+			for key, new_val := range new_values {
+				state[key] = new_val
+			}
 			m.values[gid] = state
 		}
+		var events []watchEvent
+		if found {
+			events = m.watchEvents(gid, state, added, shadowed, false)
+		}
+		depth := m.depths[gid] + 1
+		m.depths[gid] = depth
+		m.bumpGeneration(gid)
+		m.extendLock.RUnlock()
+		fireWatchers(events)
 
-		for key, new_val := range new_values {
-			mutated_keys = append(mutated_keys, key)
-			if old_val, ok := state[key]; ok {
-				mutated_vals[key] = old_val
+		if !found {
+			m.registerIfUnused()
+			if cb, _ := m.onFirstUse.Load().(func(uint32)); cb != nil {
+				cb(gid)
 			}
-			state[key] = new_val
+		}
+		if m.debug {
+			m.debugEnter(gid, passed, new_values)
 		}
 
 		defer func() {
+			if m.debug {
+				m.debugExit(gid)
+			}
+			if m.panicHandler != nil {
+				if r := recover(); r != nil {
+					m.handlePanic(gid, r)
+					// re-panic once values are restored
+					defer panic(r)
+				} else {
+					m.panicHandled(gid)
+				}
+			}
 			if !found {
-				m.values[gid] = nil
+				m.release(gid)
 				return
 			}
 
-			for _, key := range mutated_keys {
-				if val, ok := mutated_vals[key]; ok {
-					state[key] = val
-				} else {
-					delete(state, key)
-				}
+			m.extendLock.RLock()
+			events := m.watchEvents(gid, state, added, shadowed, true)
+			for _, key := range added {
+				delete(state, key)
+			}
+			for _, entry := range shadowed {
+				state[entry.key] = entry.value
 			}
+			m.depths[gid] = depth - 1
+			if meta := m.meta[gid]; meta != nil {
+				meta.closeDone(depth)
+			}
+			m.bumpGeneration(gid)
+			m.extendLock.RUnlock()
+			fireWatchers(events)
 		}()
 
 		context_call()
 	})
 }
 
+// restoreEntry records a key's value from before a SetValues call, so it can
+// be put back when the call returns.
+type restoreEntry struct {
+	key, value interface{}
+}
+
+// SetValuesFrom is like calling SetValues with base and then, within that,
+// SetValues with add, except that both are established in a single scope. Keys
+// in add take precedence over keys in base. Neither map is modified, and as
+// with SetValues, all values are restored to their previous state once
+// context_call returns. Either map may be nil; if both are nil or empty,
+// context_call is simply called.
+func (m *ContextManager) SetValuesFrom(base, add Values, context_call func()) {
+	merged := make(Values, len(base)+len(add))
+	for key, val := range base {
+		merged[key] = val
+	}
+	for key, val := range add {
+		merged[key] = val
+	}
+	m.SetValues(merged, context_call)
+}
+
 // GetValue will return a previously set value, provided that the value was set
-// by SetValues somewhere higher up the stack. If the value is not found, ok
-// will be false.
+// by SetValues somewhere higher up the stack. If the value is not found, the
+// value for key passed to SetDefaults is returned, if any, and otherwise, if
+// key is a DefaultedKey, the key's default, with ok set to true in both
+// cases. If there is no default either, ok will be false.
 func (m *ContextManager) GetValue(key interface{}) (
 	value interface{}, ok bool) {
+	if value, ok = m.lookup(key); ok {
+		return value, true
+	}
+	return m.missing(key)
+}
+
+// lookup is GetValue without the defaults: ok is false unless key was set.
+func (m *ContextManager) lookup(key interface{}) (value interface{}, ok bool) {
+	value, reason := m.lookupReason(key)
+	return value, reason == MissNone
+}
+
+// lookupReason is lookup, reporting why key wasn't found.
+func (m *ContextManager) lookupReason(key interface{}) (
+	value interface{}, reason MissReason) {
 	gid, ok := GetGoroutineId()
 	if !ok {
-		return nil, false
+		return nil, MissNoGoroutineID
 	}
 
-	state := m.values[gid]
+	state := m.state(gid)
 
 	if state == nil {
-		return nil, false
+		return nil, MissNoState
+	}
+	stored := m.normalizeKey(key)
+	value, ok = state[stored]
+	if !ok {
+		return nil, MissKeyAbsent
 	}
-	value, ok = state[key]
-	return value, ok
+	if m.debug {
+		m.debugRead(gid, stored)
+	}
+	if value, ok = m.resolve(state, stored, value); !ok {
+		return nil, MissKeyAbsent
+	}
+	return value, MissNone
 }
 
-func (m *ContextManager) getValues() Values {
+// setCurrent sets key to value in the current goroutine's values in place,
+// without arranging for it to be restored. It does nothing, and returns
+// false, if the goroutine has no values on this ContextManager or key is
+// pinned under PinIgnore; under PinPanic a pinned key panics.
+func (m *ContextManager) setCurrent(key, value interface{}) bool {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return false
+	}
+	key = m.normalizeKey(key)
+	if flags := goroutineFlags.state(gid); flags != nil {
+		if len(m.unpinned(flags, Values{key: value})) == 0 {
+			return false
+		}
+	}
+	m.extendLock.RLock()
+	defer m.extendLock.RUnlock()
+	if gid >= uint32(len(m.values)) || m.values[gid] == nil {
+		return false
+	}
+	m.values[gid][key] = value
+	m.bumpGeneration(gid)
+	return true
+}
+
+// SwapValues replaces the current goroutine's values wholesale with v and
+// returns the values it replaced, without any of the automatic restoration
+// SetValues does. It is a low-level primitive for orchestration code that
+// needs to swap entire contexts in and out, and should be paired with a
+// second SwapValues call restoring the returned map.
+//
+// Ownership of v passes to the ContextManager, which will modify it in place
+// as SetValues scopes come and go, so the caller must not use it afterwards.
+// The returned map is the live map that any SetValues scopes still active on
+// this goroutine will restore into when they exit, so it should be swapped
+// back in unmodified before they do. Failing to do so corrupts those
+// restores. A nil v clears the current goroutine's values. If the
+// ContextManager has an Option.KeyNormalizer, a normalized copy of v is
+// installed instead of v itself.
+//
+// Keys pinned with Pin keep their values: under PinIgnore they are carried
+// over into v, and under PinPanic SwapValues panics if v would drop or change
+// one.
+//
+// SwapValues panics if the current goroutine has no identifier, as the values
+// would have nowhere to live; call it within a SetValues scope (on any
+// ContextManager) or EnsureGoroutineId.
+func (m *ContextManager) SwapValues(v Values) (old Values) {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		panic("gls: SwapValues called on a goroutine without an identifier")
+	}
+	if v != nil {
+		v = m.normalizeValues(v)
+	}
+	if flags := goroutineFlags.state(gid); flags != nil {
+		v = m.keepPinned(gid, flags, v)
+	}
+	return m.swap(gid, v)
+}
+
+// keepPinned returns v with the current values of any keys pinned on this
+// manager according to flags, or panics if the manager is in PinPanic mode
+// and v would drop or change one.
+func (m *ContextManager) keepPinned(gid uint32, flags, v Values) Values {
+	current := m.state(gid)
+	for flag := range flags {
+		pin, ok := flag.(pinKey)
+		if !ok || pin.mgr != m {
+			continue
+		}
+		val, set := current[pin.key]
+		if !set {
+			continue
+		}
+		if newVal, ok := v[pin.key]; ok && sameValue(newVal, val) {
+			continue
+		}
+		if m.pinMode == PinPanic {
+			panic(fmt.Sprintf("gls: SwapValues attempted to replace pinned key %v", pin.key))
+		}
+		if v == nil {
+			v = make(Values)
+		}
+		v[pin.key] = val
+	}
+	return v
+}
+
+// swap installs v as gid's values as they are and returns the previous ones.
+func (m *ContextManager) swap(gid uint32, v Values) (old Values) {
+	m.extendIfNeeded(gid)
+	m.extendLock.RLock()
+	defer m.extendLock.RUnlock()
+	old = m.values[gid]
+	m.values[gid] = v
+	m.bumpGeneration(gid)
+	return old
+}
+
+// WithoutValues calls fn with none of this ContextManager's values visible on
+// the current goroutine, as if no SetValues scope were in effect, and puts
+// them back when fn returns or panics. Defaults still apply. It only affects
+// this ContextManager, and only on the current goroutine: other managers'
+// values stay visible, and goroutines started with Go from within fn inherit
+// nothing from this one. SetValues scopes within fn work as usual.
+func (m *ContextManager) WithoutValues(fn func()) {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		fn()
+		return
+	}
+	old := m.state(gid)
+	if old == nil {
+		fn()
+		return
+	}
+	// an empty map rather than nil, so SetValues within fn restores into it
+	// instead of releasing the goroutine's metadata on exit
+	m.swap(gid, make(Values))
+	defer m.swap(gid, old)
+	fn()
+}
+
+// Snapshot returns a copy of all values currently set on the current
+// goroutine, or nil if there are none. The copy is unaffected by any later
+// SetValues calls or scope exits, and may be handed to other goroutines. It
+// holds each key's value as GetValue would return it, so values set by
+// SetLazy are computed, and those set by SetCompressed are decompressed.
+func (m *ContextManager) Snapshot() Values {
 	gid, ok := GetGoroutineId()
 	if !ok {
 		return nil
 	}
-	state := m.values[gid]
+	state := m.state(gid)
+	values := copyValues(state)
+	for key, val := range values {
+		if resolved, ok := m.resolve(state, key, val); ok {
+			values[key] = resolved
+		} else {
+			delete(values, key)
+		}
+	}
+	return values
+}
+
+// rawSnapshot is Snapshot for values that stay within the package, such as
+// those handed to another goroutine: values are copied as they are stored,
+// so SetLazy values stay uncomputed and Cloner values stay shared.
+func (m *ContextManager) rawSnapshot(gid uint32) Values {
+	return copyValues(m.state(gid))
+}
+
+// copyValues returns a copy of values, or nil if values is empty.
+func copyValues(values Values) Values {
+	if len(values) == 0 {
+		return nil
+	}
+	copied := make(Values, len(values))
+	for key, val := range values {
+		copied[key] = val
+	}
+	return copied
+}
+
+// state returns the values for gid, or nil if this manager has never been
+// extended far enough to hold gid.
+func (m *ContextManager) state(gid uint32) (state Values) {
+	m.extendLock.RLock()
+	if gid < uint32(len(m.values)) {
+		state = m.values[gid]
+	}
+	m.extendLock.RUnlock()
 	return state
 }
 
@@ -153,20 +661,438 @@ func (m *ContextManager) getValues() Values {
 // kicking off the provided function in a new goroutine. If you don't use this
 // Go method instead of the standard 'go' keyword, you will lose values in
 // ContextManagers, as goroutines have brand new stacks.
+//
+// The copy is taken before Go returns and belongs to the new goroutine alone,
+// so the calling goroutine's SetValues scopes may change or exit while the new
+// goroutine is still running without affecting it. The copy is shallow:
+// values that are pointers, maps or slices are shared between both goroutines
+// and need their own synchronization if mutated.
 func Go(cb func()) {
+	if atomic.LoadInt32(&warnEmptyGo) != 0 {
+		warnIfEmptyGo()
+	}
+	apply := captureForGo()
+	cb = withGoHooks(cb)
+	go apply(cb)
+}
+
+// GoSeeded is like Go, but the new goroutine additionally has seed's values
+// set, on top of the ones it inherits, before cb runs: for each
+// ContextManager in seed, its Values override inherited values for the same
+// keys. This makes forking with overrides, such as a sub-request with a
+// different tenant, a single call rather than a SetValues nested in Go. The
+// managers in seed need not be registered. seed is copied before GoSeeded
+// returns.
+func GoSeeded(seed map[*ContextManager]Values, cb func()) {
+	if atomic.LoadInt32(&warnEmptyGo) != 0 {
+		warnIfEmptyGo()
+	}
+	apply := captureForGo()
+	cb = withGoHooks(cb)
+	for mgr, values := range seed {
+		if len(values) == 0 {
+			continue
+		}
+		copied := make(Values, len(values))
+		for key, val := range values {
+			copied[key] = val
+		}
+		cb = func(mgr *ContextManager, cb func()) func() {
+			return func() { mgr.SetValues(copied, cb) }
+		}(mgr, cb)
+	}
+	go apply(cb)
+}
+
+// Wrap makes a copy of all existing values on all registered context managers
+// and returns a function that calls fn with those values set. It is Go split
+// into its capture and apply phases, and is useful when a goroutine is started
+// by code you don't control, such as a library that accepts a callback:
+//
+//	server.OnRequest(gls.Wrap(handler))
+//
+// The returned function may be called any number of times, from any
+// goroutine. Every call re-establishes the values captured when Wrap was
+// called, regardless of any changes made since.
+func Wrap(fn func()) func() {
+	apply := capture()
+	return func() { apply(fn) }
+}
+
+// Capture makes a copy of all existing values on all registered context
+// managers and returns apply, which calls its argument with those values set,
+// the way Go sets them on a new goroutine. Together with Wrap, which is
+// Capture and apply in one, it is how to carry context into goroutines
+// started by code you don't control, such as a worker pool: capture on the
+// submitting goroutine, and apply in the task the pool runs. The glspool
+// package does this for common pool APIs. apply may be called any number of
+// times, from any goroutine.
+func Capture() (apply func(fn func())) {
+	return capture()
+}
+
+// capture makes a copy of all existing values on all registered context
+// managers and returns a function that calls its argument with those values
+// set.
+func capture() func(fn func()) {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return func(fn func()) { fn() }
+	}
+	return applySnapshots(snapshotRegistered(gid))
+}
+
+// snapshotRegistered takes a snapshot of every registered manager's values
+// on gid.
+func snapshotRegistered(gid uint32) (snapshots []mgrSnapshot) {
 	mgrRegistryMtx.RLock()
-	defer mgrRegistryMtx.RUnlock()
+	for mgr := range mgrRegistry {
+		snapshots = mgr.appendSnapshot(snapshots, gid)
+	}
+	mgrRegistryMtx.RUnlock()
+	return snapshots
+}
 
+type mgrSnapshot struct {
+	mgr     *ContextManager
+	values  Values
+	mutexes *mutexSet // see Mutex
+}
+
+// appendSnapshot appends a copy of m's values on gid, as they should cross
+// into another goroutine, to snapshots, if m has any.
+func (m *ContextManager) appendSnapshot(snapshots []mgrSnapshot,
+	gid uint32) []mgrSnapshot {
+	if values := m.rawSnapshot(gid); len(values) > 0 {
+		snap := mgrSnapshot{mgr: m, values: m.propagate(values)}
+		if meta := m.getMeta(gid, false); meta != nil {
+			snap.mutexes = meta.mutexes
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots
+}
+
+// applySnapshots returns a function that calls its argument with snapshots
+// set.
+func applySnapshots(snapshots []mgrSnapshot) func(fn func()) {
+	return func(fn func()) {
+		for _, snap := range snapshots {
+			fn = func(snap mgrSnapshot, fn func()) func() {
+				if snap.mutexes != nil {
+					inner := fn
+					fn = func() { snap.mgr.inheritMutexes(snap.mutexes, inner) }
+				}
+				return func() { snap.mgr.SetValues(snap.values, fn) }
+			}(snap, fn)
+		}
+		fn()
+	}
+}
+
+// captureForGo is capture as Go should do it, which is not at all within
+// WithoutPropagation, and only for the chosen managers within
+// WithGoManagers. With Option.Debug, the new goroutine checks that the
+// scopes it inherits from are still active as it starts.
+func captureForGo() func(fn func()) {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return func(fn func()) { fn() }
+	}
+	flags := goroutineFlags.state(gid)
+	if _, ok := flags[noPropagationKey{}]; ok {
+		return func(fn func()) { fn() }
+	}
+	var snapshots []mgrSnapshot
+	if mgrs, ok := flags[goManagersKey{}].([]*ContextManager); ok {
+		for _, mgr := range mgrs {
+			snapshots = mgr.appendSnapshot(snapshots, gid)
+		}
+	} else {
+		snapshots = snapshotRegistered(gid)
+	}
+	apply := applySnapshots(snapshots)
+	if check := debugGoCheck(snapshots); check != nil {
+		return func(fn func()) {
+			check()
+			apply(fn)
+		}
+	}
+	return apply
+}
+
+type goManagersKey struct{}
+
+// WithGoManagers calls fn such that any Go call made within fn propagates the
+// values of exactly the ContextManagers in mgrs, whether they are registered
+// or not, rather than those of every registered ContextManager. Like
+// WithoutPropagation, which takes precedence over it, it only affects Go
+// calls made on the current goroutine while fn is running, and is not
+// inherited by the goroutines Go starts. An inner WithGoManagers replaces the
+// set of an outer one. mgrs is copied.
+func WithGoManagers(mgrs []*ContextManager, fn func()) {
+	mgrs = append([]*ContextManager{}, mgrs...)
+	goroutineFlags.SetValues(Values{goManagersKey{}: mgrs}, fn)
+}
+
+type noPropagationKey struct{}
+
+// WithoutPropagation calls fn such that any Go call made within fn behaves
+// like the standard 'go' keyword: the new goroutine starts without any
+// ContextManager values. This is useful for isolating third-party code that
+// spawns its own goroutines from the current context. It only affects Go
+// calls made on the current goroutine while fn is running; goroutines started
+// by Go outside of fn, or from other goroutines, are unaffected.
+func WithoutPropagation(fn func()) {
+	goroutineFlags.SetValues(Values{noPropagationKey{}: true}, fn)
+}
+
+type pinKey struct {
+	mgr *ContextManager
+	key interface{}
+}
+
+// Pin sets key to value for the duration of fn, like SetValues, and
+// additionally stops any SetValues call made on the current goroutine within
+// fn from shadowing it. Depending on Option.PinMode, such an attempt either
+// silently leaves the pinned value in place (PinIgnore) or panics (PinPanic).
+// Goroutines started by Go inherit the value but not the pin.
+//
+// Besides SetValues and its variants, Override, HideKeys, SwapValues and, for
+// DefaultManager, WithValue honor pins the same way. WithoutValues,
+// ResetGoroutine and ReleaseIDs do not: they remove every value, pinned or
+// not.
+func (m *ContextManager) Pin(key, value interface{}, fn func()) {
+	key = m.normalizeKey(key)
+	m.SetValues(Values{key: value}, func() {
+		goroutineFlags.SetValues(Values{pinKey{mgr: m, key: key}: true}, fn)
+	})
+}
+
+// unpinned returns new_values without any keys pinned on this manager
+// according to flags, or panics if the manager is in PinPanic mode.
+func (m *ContextManager) unpinned(flags, new_values Values) Values {
+	var filtered Values
+	for key := range new_values {
+		if _, pinned := flags[pinKey{mgr: m, key: key}]; !pinned {
+			continue
+		}
+		if m.pinMode == PinPanic {
+			panic(fmt.Sprintf("gls: SetValues attempted to shadow pinned key %v", key))
+		}
+		if filtered == nil {
+			filtered = make(Values, len(new_values))
+			for key, val := range new_values {
+				filtered[key] = val
+			}
+		}
+		delete(filtered, key)
+	}
+	if filtered == nil {
+		return new_values
+	}
+	return filtered
+}
+
+// normalizeKey applies the Option.KeyNormalizer, if any, to key.
+func (m *ContextManager) normalizeKey(key interface{}) interface{} {
+	if m.keyNormalizer == nil {
+		return key
+	}
+	return m.keyNormalizer(key)
+}
+
+// normalizeValues returns values with the Option.KeyNormalizer, if any,
+// applied to every key.
+func (m *ContextManager) normalizeValues(values Values) Values {
+	if m.keyNormalizer == nil {
+		return values
+	}
+	normalized := make(Values, len(values))
+	for key, val := range values {
+		normalized[m.keyNormalizer(key)] = val
+	}
+	return normalized
+}
+
+// release removes all values and metadata for gid, closes any channels
+// handed out by Done, and calls the OnRelease callback, if there were any
+// values to remove.
+func (m *ContextManager) release(gid uint32) {
+	m.extendLock.RLock()
+	state, meta := m.values[gid], m.meta[gid]
+	m.values[gid] = nil
+	m.meta[gid] = nil
+	m.depths[gid] = 0
+	m.bumpGeneration(gid)
+	m.extendLock.RUnlock()
+	if meta != nil {
+		meta.closeAllDone()
+	}
+	if state == nil {
+		return
+	}
+	if cb, _ := m.onRelease.Load().(func(uint32)); cb != nil {
+		cb(gid)
+	}
+}
+
+// OnFirstUse registers cb to be called whenever a goroutine starts using this
+// ContextManager, that is, when SetValues establishes values on a goroutine
+// that had none. cb is called on that goroutine, with its identifier, once
+// the new values are set and before SetValues calls its callback. Passing nil
+// removes the callback.
+func (m *ContextManager) OnFirstUse(cb func(gid uint32)) {
+	m.onFirstUse.Store(cb)
+}
+
+// OnRelease registers cb to be called whenever a goroutine stops using this
+// ContextManager, that is, when the values established by the SetValues call
+// that triggered OnFirstUse are removed. cb is called on that goroutine, with
+// its identifier, after SetValues has finished restoring state (so GetValue
+// no longer finds anything) and before SetValues returns, including when
+// unwinding from a panic. It is called exactly once per OnFirstUse. Passing
+// nil removes the callback.
+func (m *ContextManager) OnRelease(cb func(gid uint32)) {
+	m.onRelease.Store(cb)
+}
+
+type frozenKey struct{ mgr *ContextManager }
+
+// Freeze calls fn such that any SetValues call on this ContextManager made on
+// the current goroutine within fn panics. It is meant to enforce a
+// setup-then-read discipline, where values are established up front and code
+// run inside fn may only read them. Goroutines started by Go within fn are not
+// frozen.
+func (m *ContextManager) Freeze(fn func()) {
+	goroutineFlags.SetValues(Values{frozenKey{mgr: m}: true}, fn)
+}
+
+// ResetGoroutine removes the values of every registered ContextManager, along
+// with any Freeze, Pin or WithoutPropagation in effect, from the current
+// goroutine. It is meant for goroutines that are reused across unrelated
+// units of work, such as workers in a pool that run every job inside one
+// long-lived scope: calling it at the top of each job guarantees the job
+// starts from a clean slate, even if an earlier job left values behind with
+// SwapValues or WithValue. OnRelease callbacks fire for every ContextManager
+// that had values.
+//
+// Scopes still open on the goroutine stay balanced: when they exit, they
+// restore nothing that was cleared and do not call OnRelease again.
+func ResetGoroutine() {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return
+	}
+
+	mgrRegistryMtx.RLock()
+	mgrs := make([]*ContextManager, 0, len(mgrRegistry))
+	for mgr := range mgrRegistry {
+		mgrs = append(mgrs, mgr)
+	}
+	mgrRegistryMtx.RUnlock()
+
+	// release runs OnRelease callbacks, so not under mgrRegistryMtx
+	for _, mgr := range append(mgrs, goroutineFlags) {
+		if mgr.state(gid) != nil {
+			mgr.release(gid)
+		}
+	}
+}
+
+// ReleaseIDs removes the values of every registered ContextManager, along with
+// any Freeze, Pin or WithoutPropagation in effect, from each goroutine
+// identifier in ids, as ResetGoroutine would on the goroutines themselves,
+// and returns the identifiers to the package's pool for reuse. It is meant
+// for schedulers that know when a batch of workers is done, and takes each
+// ContextManager's lock once, and returns the identifiers in one call that is
+// safe against concurrent Acquires. OnRelease callbacks fire, on the calling
+// goroutine, for every identifier that had values.
+//
+// Only identifiers the pool has handed out, those below
+// ReadStats().MaxGoroutineID, are returned to it; others, such as those
+// supplied by SetGoroutineIDFunc above that range, are only cleared. An
+// identifier the package handed out is normally returned by its goroutine's
+// outermost scope as it exits, so pass one only if its goroutine is gone
+// without having done so: releasing an identifier that is still in use, or
+// that will still be returned, hands it out twice.
+func ReleaseIDs(ids []uint32) {
+	if len(ids) == 0 {
+		return
+	}
+	defer func() {
+		issued := atomic.LoadUint32(&stackTagPool.curID)
+		pooled := make([]uint32, 0, len(ids))
+		for _, id := range ids {
+			if id < issued {
+				pooled = append(pooled, id)
+			}
+		}
+		if len(pooled) > 0 {
+			stackTagPool.ReleaseMany(pooled)
+		}
+	}()
+	mgrRegistryMtx.RLock()
+	mgrs := make([]*ContextManager, 0, len(mgrRegistry))
 	for mgr := range mgrRegistry {
-		values := mgr.getValues()
-		if len(values) > 0 {
-			cb = func(mgr *ContextManager, cb func()) func() {
-				return func() { mgr.SetValues(values, cb) }
-			}(mgr, cb)
+		mgrs = append(mgrs, mgr)
+	}
+	mgrRegistryMtx.RUnlock()
+
+	for _, mgr := range append(mgrs, goroutineFlags) {
+		mgr.releaseMany(ids)
+	}
+}
+
+// releaseMany is release for several goroutines at once, which may be other
+// than the current one.
+func (m *ContextManager) releaseMany(ids []uint32) {
+	var released []uint32
+	var metas []*goroutineMeta
+	m.extendLock.Lock()
+	for _, gid := range ids {
+		if gid >= uint32(len(m.values)) {
+			continue
+		}
+		if m.values[gid] != nil {
+			released = append(released, gid)
 		}
+		if meta := m.meta[gid]; meta != nil {
+			metas = append(metas, meta)
+		}
+		m.values[gid] = nil
+		m.meta[gid] = nil
+		m.depths[gid] = 0
+		m.bumpGeneration(gid)
+	}
+	m.extendLock.Unlock()
+	for _, meta := range metas {
+		meta.closeAllDone()
 	}
+	if cb, _ := m.onRelease.Load().(func(uint32)); cb != nil {
+		for _, gid := range released {
+			cb(gid)
+		}
+	}
+}
 
-	go cb()
+// Cleanup is ResetGoroutine meant for a deferred call at the top of a
+// goroutine, as a last line of defense for framework code that must not leave
+// anything behind once the goroutine is done:
+//
+//	go func() {
+//		defer gls.Cleanup()
+//		...
+//	}()
+//
+// It is not needed for values set with SetValues and the functions built on
+// it, which always unwind as their scopes exit, even through panics and
+// runtime.Goexit. It is needed for values that bypass scopes, such as those
+// installed by SwapValues on a goroutine with no SetValues scope of its own
+// to restore into: they would otherwise outlive the goroutine, and show up
+// for whichever goroutine is next given the same identifier.
+func Cleanup() {
+	ResetGoroutine()
 }
 
 func (m *ContextManager) extend(gid uint32) {
@@ -175,7 +1101,11 @@ func (m *ContextManager) extend(gid uint32) {
 	if gid >= uint32(m.currentMaxGoroutineCount) {
 		unit := ((gid-uint32(m.currentMaxGoroutineCount))/m.extendUnit + 1) * m.extendUnit
 		m.values = append(m.values, make([]Values, unit)...)
+		m.meta = append(m.meta, make([]*goroutineMeta, unit)...)
+		m.generations = append(m.generations, make([]uint64, unit)...)
+		m.depths = append(m.depths, make([]uint32, unit)...)
 		m.currentMaxGoroutineCount += int(unit)
+		atomic.AddUint64(&extendEvents, 1)
 	}
 }
 
@@ -1,7 +1,11 @@
 package gls
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -63,6 +67,888 @@ func TestContexts(t *testing.T) {
 	Check("", "", "", "")
 }
 
+func TestWithoutPropagation(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	check := func(exp bool) {
+		_, ok := mgr.GetValue("key")
+		if ok != exp {
+			t.Fatalf("expected value presence %v, got %v", exp, ok)
+		}
+	}
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		var wg sync.WaitGroup
+		WithoutPropagation(func() {
+			check(true)
+			wg.Add(1)
+			Go(func() {
+				defer wg.Done()
+				check(false)
+			})
+			wg.Wait()
+		})
+		wg.Add(1)
+		Go(func() {
+			defer wg.Done()
+			check(true)
+		})
+		wg.Wait()
+	})
+}
+
+func TestWithGoManagers(t *testing.T) {
+	registered := NewContextManager(Option{})
+	defer registered.Unregister()
+	chosen := NewContextManager(Option{})
+	defer chosen.Unregister()
+	// unregistered managers can be propagated too
+	private := newContextManager(Option{})
+
+	inChild := func() (seen []string) {
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			for name, mgr := range map[string]*ContextManager{
+				"registered": registered, "chosen": chosen, "private": private} {
+				if _, ok := mgr.GetValue("key"); ok {
+					seen = append(seen, name)
+				}
+			}
+		})
+		<-done
+		sort.Strings(seen)
+		return seen
+	}
+
+	registered.SetValues(Values{"key": 1}, func() {
+		chosen.SetValues(Values{"key": 2}, func() {
+			private.SetValues(Values{"key": 3}, func() {
+				WithGoManagers([]*ContextManager{chosen, private}, func() {
+					if seen := inChild(); fmt.Sprint(seen) != "[chosen private]" {
+						t.Fatalf("expected only chosen managers, got %v", seen)
+					}
+					WithoutPropagation(func() {
+						if seen := inChild(); len(seen) != 0 {
+							t.Fatalf("expected nothing propagated, got %v", seen)
+						}
+					})
+				})
+				if seen := inChild(); fmt.Sprint(seen) != "[chosen registered]" {
+					t.Fatalf("expected registered managers after the scope, got %v",
+						seen)
+				}
+			})
+		})
+	})
+}
+
+func TestPin(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.Pin("id", "outer", func() {
+		mgr.SetValues(Values{"id": "inner", "other": "val"}, func() {
+			if val, _ := mgr.GetValue("id"); val != "outer" {
+				t.Fatalf("expected pinned value outer, got %v", val)
+			}
+			if val, _ := mgr.GetValue("other"); val != "val" {
+				t.Fatalf("expected unpinned value val, got %v", val)
+			}
+		})
+		if _, ok := mgr.GetValue("other"); ok {
+			t.Fatalf("expected other to be restored")
+		}
+
+		if mgr.setCurrent("id", "set") {
+			t.Fatalf("expected setting a pinned key in place to be refused")
+		}
+		old := mgr.SwapValues(Values{"other": "swapped"})
+		if val, _ := mgr.GetValue("id"); val != "outer" {
+			t.Fatalf("expected pinned value to survive SwapValues, got %v", val)
+		}
+		mgr.SwapValues(old)
+		if val, _ := mgr.GetValue("id"); val != "outer" {
+			t.Fatalf("expected pinned value outer, got %v", val)
+		}
+	})
+	if _, ok := mgr.GetValue("id"); ok {
+		t.Fatalf("expected pinned value to be removed after Pin returns")
+	}
+	mgr.SetValues(Values{"id": "after"}, func() {
+		if val, _ := mgr.GetValue("id"); val != "after" {
+			t.Fatalf("expected unpinned key to be settable, got %v", val)
+		}
+	})
+}
+
+func TestPinPanic(t *testing.T) {
+	mgr := NewContextManager(Option{PinMode: PinPanic})
+	defer mgr.Unregister()
+
+	mgr.Pin("id", "outer", func() {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected SetValues of pinned key to panic")
+				}
+			}()
+			mgr.SetValues(Values{"id": "inner"}, func() {
+				t.Fatalf("expected SetValues not to run its callback")
+			})
+		}()
+		for name, set := range map[string]func(){
+			"setCurrent": func() { mgr.setCurrent("id", "set") },
+			"SwapValues": func() { mgr.SwapValues(nil) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatalf("expected %s of pinned key to panic", name)
+					}
+				}()
+				set()
+			}()
+		}
+		// leaving the pinned value as it is is allowed
+		mgr.SwapValues(mgr.SwapValues(Values{"id": "outer"}))
+		if val, _ := mgr.GetValue("id"); val != "outer" {
+			t.Fatalf("expected pinned value outer, got %v", val)
+		}
+	})
+}
+
+func TestWrap(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var wrapped func()
+	calls := 0
+	mgr.SetValues(Values{"key": "val"}, func() {
+		wrapped = Wrap(func() {
+			calls++
+			if val, ok := mgr.GetValue("key"); !ok || val != "val" {
+				t.Fatalf("expected wrapped value val, got %v", val)
+			}
+		})
+		mgr.SetValues(Values{"key": "changed"}, func() {
+			wrapped()
+		})
+	})
+
+	if _, ok := mgr.GetValue("key"); ok {
+		t.Fatalf("expected no value outside of SetValues")
+	}
+	wrapped()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wrapped()
+	}()
+	<-done
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestGoSeeded(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	other := NewContextManager(Option{})
+	defer other.Unregister()
+
+	seed := map[*ContextManager]Values{
+		mgr:   {"tenant": "sub"},
+		other: {"trace": "t2"},
+	}
+	done := make(chan Values)
+	mgr.SetValues(Values{"tenant": "main", "request": "1"}, func() {
+		GoSeeded(seed, func() {
+			tenant, _ := mgr.GetValue("tenant")
+			request, _ := mgr.GetValue("request")
+			trace, _ := other.GetValue("trace")
+			done <- Values{"tenant": tenant, "request": request, "trace": trace}
+		})
+		seed[mgr]["tenant"] = "changed"
+		got := <-done
+		for key, exp := range map[string]string{
+			"tenant": "sub", "request": "1", "trace": "t2"} {
+			if got[key] != exp {
+				t.Fatalf("expected %s for %s in child, got %v", exp, key, got[key])
+			}
+		}
+		if val, _ := mgr.GetValue("tenant"); val != "main" {
+			t.Fatalf("expected parent tenant to be unchanged, got %v", val)
+		}
+	})
+}
+
+func TestFreeze(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	other := NewContextManager(Option{})
+	defer other.Unregister()
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		mgr.Freeze(func() {
+			if val, _ := mgr.GetValue("key"); val != "val" {
+				t.Fatalf("expected value val while frozen, got %v", val)
+			}
+			other.SetValues(Values{"key": "other"}, func() {})
+
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected SetValues within Freeze to panic")
+				}
+			}()
+			mgr.SetValues(Values{"key": "changed"}, func() {
+				t.Fatalf("expected SetValues not to run its callback")
+			})
+		})
+		mgr.SetValues(Values{"key": "changed"}, func() {})
+	})
+}
+
+func TestSetValuesE(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if !isolated(t) {
+		return
+	}
+	SetMaxGoroutines(1, false)
+	defer SetMaxGoroutines(0, false)
+
+	called := false
+	err := mgr.SetValuesE(Values{"key": "val"}, func() {
+		called = true
+		if val, _ := mgr.GetValue("key"); val != "val" {
+			t.Errorf("expected value val, got %v", val)
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- mgr.SetValuesE(Values{"key": "other"}, func() {
+				t.Errorf("expected exhausted SetValuesE not to call back")
+			})
+		}()
+		if err := <-errs; err != ErrIDExhausted {
+			t.Errorf("expected ErrIDExhausted, got %v", err)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != ErrIDExhausted {
+					t.Errorf("expected SetValues to panic with ErrIDExhausted, got %v", r)
+				}
+			}()
+			done := make(chan interface{})
+			go func() {
+				defer func() { done <- recover() }()
+				mgr.SetValues(Values{"key": "other"}, func() {})
+			}()
+			if r := <-done; r != nil {
+				panic(r)
+			}
+		}()
+	})
+	if err != nil || !called {
+		t.Fatalf("expected successful SetValuesE, got %v (called %v)", err, called)
+	}
+}
+
+func TestSetValuesRestore(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	// more keys than SetValues remembers without allocating, half of them
+	// overwriting existing ones
+	outer, inner := make(Values), make(Values)
+	for i := 0; i < 10; i++ {
+		outer[i] = "outer"
+	}
+	for i := 5; i < 15; i++ {
+		inner[i] = "inner"
+	}
+	mgr.SetValues(outer, func() {
+		mgr.SetValues(inner, func() {
+			for i := 0; i < 15; i++ {
+				expected := "inner"
+				if i < 5 {
+					expected = "outer"
+				}
+				if val, _ := mgr.GetValue(i); val != expected {
+					t.Fatalf("expected %s for key %d, got %v", expected, i, val)
+				}
+			}
+		})
+		for i := 0; i < 15; i++ {
+			val, ok := mgr.GetValue(i)
+			if i < 10 && val != "outer" || i >= 10 && ok {
+				t.Fatalf("expected key %d to be restored, got %v", i, val)
+			}
+		}
+
+		// only new keys, so nothing is shadowed
+		added := make(Values)
+		for i := 10; i < 25; i++ {
+			added[i] = "added"
+		}
+		mgr.SetValues(added, func() {
+			if val, _ := mgr.GetValue(20); val != "added" {
+				t.Fatalf("expected added, got %v", val)
+			}
+		})
+		for i := 0; i < 25; i++ {
+			val, ok := mgr.GetValue(i)
+			if i < 10 && val != "outer" || i >= 10 && ok {
+				t.Fatalf("expected key %d to be restored, got %v", i, val)
+			}
+		}
+	})
+}
+
+func TestSetValuesFrom(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	base := Values{"a": "base", "b": "base"}
+	add := Values{"b": "add", "c": "add"}
+
+	mgr.SetValues(Values{"a": "outer", "d": "outer"}, func() {
+		mgr.SetValuesFrom(base, add, func() {
+			for key, exp := range map[string]string{
+				"a": "base", "b": "add", "c": "add", "d": "outer"} {
+				if val, _ := mgr.GetValue(key); val != exp {
+					t.Fatalf("expected value %s for key %s, got %v", exp, key, val)
+				}
+			}
+		})
+		if val, _ := mgr.GetValue("a"); val != "outer" {
+			t.Fatalf("expected a to be restored to outer, got %v", val)
+		}
+		for _, key := range []string{"b", "c"} {
+			if _, ok := mgr.GetValue(key); ok {
+				t.Fatalf("expected key %s to be removed", key)
+			}
+		}
+	})
+	if len(base) != 2 || len(add) != 2 {
+		t.Fatalf("expected SetValuesFrom not to modify its arguments")
+	}
+}
+
+func TestOnFirstUse(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var mtx sync.Mutex
+	var gids []uint32
+	mgr.OnFirstUse(func(gid uint32) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		gids = append(gids, gid)
+	})
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		mgr.SetValues(Values{"key2": "val2"}, func() {})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		Go(func() {
+			defer wg.Done()
+			mgr.SetValues(Values{"key3": "val3"}, func() {})
+		})
+		wg.Wait()
+	})
+
+	if len(gids) != 2 {
+		t.Fatalf("expected 2 first uses, got %d", len(gids))
+	}
+	if gids[0] == gids[1] {
+		t.Fatalf("expected first uses on distinct goroutines, got %v", gids)
+	}
+
+	mgr.OnFirstUse(nil)
+	mgr.SetValues(Values{"key": "val"}, func() {})
+	if len(gids) != 2 {
+		t.Fatalf("expected no calls after removing callback, got %d", len(gids))
+	}
+}
+
+func TestOnRelease(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var firstUses, releases []uint32
+	mgr.OnFirstUse(func(gid uint32) { firstUses = append(firstUses, gid) })
+	mgr.OnRelease(func(gid uint32) {
+		if _, ok := mgr.GetValue("key"); ok {
+			t.Fatalf("expected values to be gone when OnRelease is called")
+		}
+		releases = append(releases, gid)
+	})
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		mgr.SetValues(Values{"key": "val2"}, func() {})
+		if len(releases) != 0 {
+			t.Fatalf("expected no release from a nested scope")
+		}
+	})
+	func() {
+		defer func() { recover() }()
+		mgr.SetValues(Values{"key": "val"}, func() { panic("boom") })
+	}()
+
+	if len(releases) != 2 || len(firstUses) != 2 {
+		t.Fatalf("expected 2 first uses and 2 releases, got %d and %d",
+			len(firstUses), len(releases))
+	}
+	for i := range releases {
+		if releases[i] != firstUses[i] {
+			t.Fatalf("expected release of gid %d, got %d", firstUses[i],
+				releases[i])
+		}
+	}
+}
+
+func TestResetGoroutine(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var releases int
+	mgr.OnRelease(func(gid uint32) { releases++ })
+
+	// a pooled worker running every job inside one long-lived scope
+	jobs := []func(){
+		func() {
+			mgr.SwapValues(Values{"request": "first"})
+		},
+		func() {
+			if val, ok := mgr.GetValue("request"); ok {
+				t.Fatalf("expected no leftover value, got %v", val)
+			}
+			mgr.SetValues(Values{"request": "second"}, func() {
+				if val, _ := mgr.GetValue("request"); val != "second" {
+					t.Fatalf("expected second, got %v", val)
+				}
+			})
+		},
+	}
+	mgr.SetValues(Values{"worker": 1}, func() {
+		mgr.Freeze(func() {
+			for _, job := range jobs {
+				ResetGoroutine()
+				job()
+			}
+		})
+	})
+
+	if releases != 3 {
+		t.Fatalf("expected 3 releases, got %d", releases)
+	}
+	if _, ok := mgr.GetValue("worker"); ok {
+		t.Fatalf("expected no values after all scopes exited")
+	}
+	ResetGoroutine()
+}
+
+func TestReleaseIDs(t *testing.T) {
+	if !isolated(t) {
+		return
+	}
+
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var released []uint32
+	mgr.OnRelease(func(gid uint32) { released = append(released, gid) })
+
+	// logical workers 900 to 902 leave values behind
+	var worker uint32
+	SetGoroutineIDFunc(func() (uint32, bool) { return worker, worker != 0 })
+	defer SetGoroutineIDFunc(nil)
+	for worker = 900; worker < 903; worker++ {
+		mgr.SwapValues(Values{"worker": worker})
+	}
+
+	ReleaseIDs([]uint32{900, 901, 902, 5000})
+	if len(released) != 3 {
+		t.Fatalf("expected 3 releases, got %v", released)
+	}
+	for worker = 900; worker < 903; worker++ {
+		if val, ok := mgr.GetValue("worker"); ok {
+			t.Fatalf("expected worker %d to be cleared, got %v", worker, val)
+		}
+		// the identifier can be reused with a clean slate
+		mgr.SetValues(Values{"job": "next"}, func() {
+			if snapshot := mgr.Snapshot(); len(snapshot) != 1 {
+				t.Fatalf("expected only the new job's values, got %v", snapshot)
+			}
+		})
+	}
+	worker = 0
+}
+
+func TestReleaseIDsReuse(t *testing.T) {
+	if !isolated(t) {
+		return
+	}
+
+	// identifiers whose goroutines went away without returning them
+	leaked := map[uint32]bool{}
+	for i := 0; i < 3; i++ {
+		id, err := stackTagPool.Acquire()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		leaked[id] = true
+	}
+	ids := []uint32{1 << 30}
+	for id := range leaked {
+		ids = append(ids, id)
+	}
+
+	ReleaseIDs(ids)
+	for i := 0; i < 3; i++ {
+		id, err := stackTagPool.Acquire()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !leaked[id] {
+			t.Fatalf("expected a released id out of %v, got %d", leaked, id)
+		}
+		delete(leaked, id)
+	}
+	if id, _ := stackTagPool.Acquire(); id == 1<<30 {
+		t.Fatalf("expected an id the pool never issued to stay out of it")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var releases int
+	mgr.OnRelease(func(gid uint32) { releases++ })
+
+	unbalanced := func(cleanup bool) (gid uint32) {
+		EnsureGoroutineId(func(id uint32) {
+			gid = id
+			if cleanup {
+				defer Cleanup()
+			}
+			mgr.SwapValues(Values{"key": "stale"})
+		})
+		return gid
+	}
+
+	if gid := unbalanced(false); mgr.state(gid) == nil {
+		t.Fatalf("expected SwapValues without a scope to leave an entry behind")
+	} else {
+		mgr.release(gid)
+	}
+	releases = 0
+	if gid := unbalanced(true); mgr.state(gid) != nil {
+		t.Fatalf("expected Cleanup to remove the entry")
+	}
+	if releases != 1 {
+		t.Fatalf("expected Cleanup to release once, got %d", releases)
+	}
+}
+
+func TestNilValues(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var firstUses int
+	mgr.OnFirstUse(func(gid uint32) { firstUses++ })
+
+	setters := map[string]func(fn func()){
+		"SetValues": func(fn func()) { mgr.SetValues(nil, fn) },
+		"SetValuesE": func(fn func()) {
+			if err := mgr.SetValuesE(nil, fn); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		},
+		"SetValuesFrom": func(fn func()) { mgr.SetValuesFrom(nil, nil, fn) },
+		"Scope": func(fn func()) {
+			mgr.Scope(context.Background(), nil, func(ctx context.Context) { fn() })
+		},
+		"RunWithToken": func(fn func()) { mgr.RunWithToken(Token{}, fn) },
+	}
+	for name, set := range setters {
+		var called bool
+		set(func() {
+			called = true
+			if values := mgr.Snapshot(); values != nil {
+				t.Fatalf("expected %s not to set anything, got %v", name, values)
+			}
+		})
+		if !called {
+			t.Fatalf("expected %s to call fn", name)
+		}
+		if firstUses != 0 {
+			t.Fatalf("expected %s not to create an entry", name)
+		}
+	}
+
+	EnsureGoroutineId(func(gid uint32) {
+		mgr.SetValues(Values{"key": "val"}, func() {
+			for name, set := range setters {
+				set(func() {
+					if val, _ := mgr.GetValue("key"); val != "val" {
+						t.Fatalf("expected %s to leave existing values, got %v",
+							name, val)
+					}
+				})
+			}
+		})
+		if state := mgr.state(gid); state != nil {
+			t.Fatalf("expected no leftover entry, got %v", state)
+		}
+	})
+}
+
+func TestSwapValues(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"key": "orig"}, func() {
+		old := mgr.SwapValues(Values{"key": "swapped", "other": "val"})
+		if old["key"] != "orig" {
+			t.Fatalf("expected old values to contain orig, got %v", old)
+		}
+		if val, _ := mgr.GetValue("key"); val != "swapped" {
+			t.Fatalf("expected swapped value, got %v", val)
+		}
+		mgr.SetValues(Values{"key": "nested"}, func() {
+			if val, _ := mgr.GetValue("key"); val != "nested" {
+				t.Fatalf("expected nested value, got %v", val)
+			}
+		})
+		if val, _ := mgr.GetValue("key"); val != "swapped" {
+			t.Fatalf("expected swapped value after nested scope, got %v", val)
+		}
+
+		if swapped := mgr.SwapValues(nil); swapped["other"] != "val" {
+			t.Fatalf("expected swapped values back, got %v", swapped)
+		}
+		if _, ok := mgr.GetValue("key"); ok {
+			t.Fatalf("expected no values after swapping in nil")
+		}
+
+		mgr.SwapValues(old)
+		if val, _ := mgr.GetValue("key"); val != "orig" {
+			t.Fatalf("expected original value after restoring, got %v", val)
+		}
+		if _, ok := mgr.GetValue("other"); ok {
+			t.Fatalf("expected swapped-only key to be gone")
+		}
+	})
+	if _, ok := mgr.GetValue("key"); ok {
+		t.Fatalf("expected no values outside of SetValues")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected SwapValues without an identifier to panic")
+		}
+	}()
+	mgr.SwapValues(Values{"key": "val"})
+}
+
+func TestWithoutValues(t *testing.T) {
+	mgr := NewContextManager(Option{KeyNormalizer: func(key interface{}) interface{} {
+		return strings.ToLower(key.(string))
+	}})
+	defer mgr.Unregister()
+	other := NewContextManager(Option{})
+	defer other.Unregister()
+
+	mgr.SetValues(Values{"Key": "val"}, func() {
+		other.SetValues(Values{"other": "val"}, func() {
+			mgr.WithoutValues(func() {
+				if val, ok := mgr.GetValue("key"); ok {
+					t.Fatalf("expected no value inside WithoutValues, got %v", val)
+				}
+				if val, _ := other.GetValue("other"); val != "val" {
+					t.Fatalf("expected other managers to be unaffected, got %v", val)
+				}
+				mgr.SetValues(Values{"inner": "val"}, func() {
+					if val, _ := mgr.GetValue("inner"); val != "val" {
+						t.Fatalf("expected inner scopes to work, got %v", val)
+					}
+				})
+			})
+		})
+
+		func() {
+			defer func() { recover() }()
+			mgr.WithoutValues(func() { panic("boom") })
+		}()
+		if val, _ := mgr.GetValue("key"); val != "val" {
+			t.Fatalf("expected the value to reappear, got %v", val)
+		}
+		if _, ok := mgr.GetValue("inner"); ok {
+			t.Fatalf("expected the inner value to be gone")
+		}
+	})
+	if _, ok := mgr.GetValue("key"); ok {
+		t.Fatalf("expected the scope to restore normally")
+	}
+
+	called := false
+	mgr.WithoutValues(func() { called = true })
+	if !called {
+		t.Fatalf("expected fn to be called without an identifier")
+	}
+}
+
+func TestWithoutValuesNested(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	var firstUses, releases int
+	mgr.OnFirstUse(func(uint32) { firstUses++ })
+	mgr.OnRelease(func(uint32) { releases++ })
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		done := mgr.Done()
+		mgr.AppendError(errors.New("outer"))
+		mgr.WithoutValues(func() {
+			mgr.SetValues(Values{"inner": "val"}, func() {})
+		})
+		select {
+		case <-done:
+			t.Fatalf("expected Done not to be closed while the scope is active")
+		default:
+		}
+		if errs := mgr.Errors(); len(errs) != 1 {
+			t.Fatalf("expected the outer error to survive, got %v", errs)
+		}
+		if val, _ := mgr.GetValue("key"); val != "val" {
+			t.Fatalf("expected the value to reappear, got %v", val)
+		}
+	})
+	if firstUses != 1 || releases != 1 {
+		t.Fatalf("expected one first use and one release, got %d and %d",
+			firstUses, releases)
+	}
+}
+
+func TestGoWhileUnregistering(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	stop := make(chan struct{})
+	churned := make(chan struct{})
+	go func() {
+		defer close(churned)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			churn := NewContextManager(Option{})
+			churn.SetValues(Values{"key": "churn"}, func() {
+				churn.Unregister()
+			})
+		}
+	}()
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(1)
+			Go(func() {
+				defer wg.Done()
+				if val, _ := mgr.GetValue("key"); val != "val" {
+					t.Errorf("expected value val, got %v", val)
+				}
+			})
+		}
+		wg.Wait()
+	})
+	close(stop)
+	<-churned
+}
+
+func TestGoOutlivesScope(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var wg sync.WaitGroup
+	started := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		mgr.SetValues(Values{"key": i}, func() {
+			mgr.SetValues(Values{"key2": i}, func() {
+				wg.Add(1)
+				Go(func(i int) func() {
+					return func() {
+						defer wg.Done()
+						<-started
+						for j := 0; j < 100; j++ {
+							if val, _ := mgr.GetValue("key"); val != i {
+								t.Errorf("expected value %d, got %v", i, val)
+								return
+							}
+							if val, _ := mgr.GetValue("key2"); val != i {
+								t.Errorf("expected value %d, got %v", i, val)
+								return
+							}
+						}
+					}
+				}(i))
+			})
+			mgr.SetValues(Values{"key": "changed"}, func() {})
+		})
+	}
+	close(started)
+	mgr.SetValues(Values{"key": "reused"}, func() {
+		mgr.SetValues(Values{"key2": "reused"}, func() {})
+	})
+	wg.Wait()
+}
+
+func TestKeyNormalizer(t *testing.T) {
+	mgr := NewContextManager(Option{
+		KeyNormalizer: func(key interface{}) interface{} {
+			if str, ok := key.(string); ok {
+				return strings.ToLower(str)
+			}
+			return key
+		},
+	})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"ID": "outer", 1: "one"}, func() {
+		if val, _ := mgr.GetValue("id"); val != "outer" {
+			t.Fatalf("expected normalized lookup to find outer, got %v", val)
+		}
+		if val, _ := mgr.GetValue(1); val != "one" {
+			t.Fatalf("expected non-string key to be unaffected, got %v", val)
+		}
+		mgr.SetValues(Values{"Id": "inner"}, func() {
+			if val, _ := mgr.GetValue("ID"); val != "inner" {
+				t.Fatalf("expected normalized keys to share a slot, got %v", val)
+			}
+		})
+		if val, _ := mgr.GetValue("iD"); val != "outer" {
+			t.Fatalf("expected outer value to be restored, got %v", val)
+		}
+		mgr.Pin("Pinned", "pin", func() {
+			mgr.SetValues(Values{"PINNED": "shadow"}, func() {
+				if val, _ := mgr.GetValue("pinned"); val != "pin" {
+					t.Fatalf("expected pin to apply to normalized keys, got %v", val)
+				}
+			})
+		})
+	})
+}
+
 func ExampleContextManager_SetValues() {
 	var (
 		mgr            = NewContextManager(Option{})
@@ -86,6 +972,43 @@ func ExampleContextManager_SetValues() {
 	// No request id found
 }
 
+func ExampleContextManager_Attach() {
+	var (
+		mgr            = NewContextManager(Option{})
+		request_id_key = GenSym()
+	)
+
+	type job struct {
+		snapshot Values
+		work     func()
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+
+	// a worker started up front, outside of any request
+	go func() {
+		for j := range jobs {
+			mgr.Attach(j.snapshot, j.work)
+			wg.Done()
+		}
+	}()
+
+	for _, request_id := range []string{"12345", "67890"} {
+		mgr.SetValues(Values{request_id_key: request_id}, func() {
+			wg.Add(1)
+			jobs <- job{snapshot: mgr.Snapshot(), work: func() {
+				request_id, _ := mgr.GetValue(request_id_key)
+				fmt.Println("Working on request:", request_id)
+			}}
+			wg.Wait()
+		})
+	}
+	close(jobs)
+
+	// Output: Working on request: 12345
+	// Working on request: 67890
+}
+
 func ExampleGo() {
 	var (
 		mgr            = NewContextManager(Option{})
@@ -154,6 +1077,168 @@ func BenchmarkSetValues(b *testing.B) {
 	wg.Wait()
 }
 
+func BenchmarkGo50Keys(b *testing.B) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	values := make(Values, 50)
+	for i := 0; i < 50; i++ {
+		values[i] = i
+	}
+	var wg sync.WaitGroup
+	mgr.SetValues(values, func() {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			wg.Add(1)
+			Go(wg.Done)
+		}
+		wg.Wait()
+	})
+}
+
+func BenchmarkSetValuesNested(b *testing.B) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	base := Values{"key1": "val1", "key2": "val2"}
+	add := Values{"key3": "val3"}
+	EnsureGoroutineId(func(gid uint32) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mgr.SetValues(base, func() {
+				mgr.SetValues(add, func() {})
+			})
+		}
+	})
+}
+
+func BenchmarkSetValuesFresh(b *testing.B) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	values := make(Values, 16)
+	for i := 0; i < 16; i++ {
+		values[i] = i
+	}
+	EnsureGoroutineId(func(gid uint32) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mgr.SetValues(values, func() {})
+		}
+	})
+}
+
+func BenchmarkSetValuesOverwrite(b *testing.B) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	values := make(Values, 8)
+	for i := 0; i < 8; i++ {
+		values[i] = i
+	}
+	mgr.SetValues(values, func() {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mgr.SetValues(values, func() {})
+		}
+	})
+}
+
+func BenchmarkSetValuesAdd(b *testing.B) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	values := make(Values, 8)
+	for i := 0; i < 8; i++ {
+		values[i] = i
+	}
+	mgr.SetValues(Values{"base": "val"}, func() {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mgr.SetValues(values, func() {})
+		}
+	})
+}
+
+func BenchmarkSetValuesShapes(b *testing.B) {
+	for _, n := range []int{4, 16} {
+		values := make(Values, n)
+		for i := 0; i < n; i++ {
+			values[i] = i
+		}
+		// half the keys already set, half new
+		mixed := make(Values, n/2)
+		for i := 0; i < n/2; i++ {
+			mixed[i] = i
+		}
+		for _, shape := range []struct {
+			name string
+			base Values
+		}{
+			{"fresh", nil},
+			{"new", Values{"base": "val"}},
+			{"overwrite", values},
+			{"mixed", mixed},
+		} {
+			b.Run(fmt.Sprintf("%s/%d", shape.name, n), func(b *testing.B) {
+				mgr := NewContextManager(Option{})
+				defer mgr.Unregister()
+				EnsureGoroutineId(func(gid uint32) {
+					mgr.SetValues(shape.base, func() {
+						b.ReportAllocs()
+						b.ResetTimer()
+						for i := 0; i < b.N; i++ {
+							mgr.SetValues(values, func() {})
+						}
+					})
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkSetValuesFrom(b *testing.B) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	base := Values{"key1": "val1", "key2": "val2"}
+	add := Values{"key3": "val3"}
+	EnsureGoroutineId(func(gid uint32) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mgr.SetValuesFrom(base, add, func() {})
+		}
+	})
+}
+
+func TestNewContextManagerE(t *testing.T) {
+	for _, option := range []Option{
+		{InitialMaxGoroutineCount: -1},
+		{ExtendUnit: -128},
+		{PinMode: PinMode(7)},
+		{PrimaryKey: []string{"not", "comparable"}},
+		{ChildIDKey: "span"},
+		{ChildIDKey: []string{"span"},
+			ChildIDDerive: func(interface{}) interface{} { return nil }},
+	} {
+		mgr, err := NewContextManagerE(option)
+		if mgr != nil || !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("expected ErrInvalidOption for %+v, got %v", option, err)
+		}
+	}
+
+	mgr, err := NewContextManagerE(Option{ExtendUnit: 16, PinMode: PinPanic,
+		PrimaryKey: RequestIDKey})
+	if err != nil {
+		t.Fatalf("expected a valid Option, got %v", err)
+	}
+	mgr.Unregister()
+
+	defer func() {
+		if r, _ := recover().(error); !errors.Is(r, ErrInvalidOption) {
+			t.Fatalf("expected NewContextManager to panic, got %v", r)
+		}
+	}()
+	NewContextManager(Option{ExtendUnit: -1})
+}
+
 func TestExtend(t *testing.T) {
 	lenCheck := func(values []Values, expected int) {
 		if len(values) != expected {
@@ -169,3 +1254,55 @@ func TestExtend(t *testing.T) {
 	mgr.extend(initialMaxGoroutineCount + extendUnit*10)
 	lenCheck(mgr.values, initialMaxGoroutineCount+extendUnit*11)
 }
+
+func TestChildID(t *testing.T) {
+	derived := 0
+	mgr := NewContextManager(Option{
+		ChildIDKey: "span",
+		ChildIDDerive: func(parent interface{}) interface{} {
+			derived++
+			return parent.(string) + ".1"
+		},
+	})
+	defer mgr.Unregister()
+
+	child := func() Values {
+		done := make(chan Values)
+		Go(func() { done <- mgr.Snapshot() })
+		return <-done
+	}
+
+	mgr.SetValues(Values{"span": "1", "user": "bob"}, func() {
+		values := child()
+		if values["span"] != "1.1" || values["user"] != "bob" {
+			t.Fatalf("expected a derived span and an unchanged user, got %v", values)
+		}
+		if val, _ := mgr.GetValue("span"); val != "1" {
+			t.Fatalf("expected the parent's span to be unchanged, got %v", val)
+		}
+	})
+
+	mgr.SetValues(Values{"user": "bob"}, func() {
+		if values := child(); len(values) != 1 || values["user"] != "bob" {
+			t.Fatalf("expected only user in the child, got %v", values)
+		}
+	})
+	if derived != 1 {
+		t.Fatalf("expected ChildIDDerive to be called once, got %d", derived)
+	}
+
+	mgr.SetValues(Values{"span": "2"}, func() {
+		token := mgr.Capture()
+		snapshot := mgr.Snapshot()
+		mgr.RunWithToken(token, func() {
+			if val, _ := mgr.GetValue("span"); val != "2.1" {
+				t.Fatalf("expected a derived span from a Token, got %v", val)
+			}
+		})
+		mgr.Attach(snapshot, func() {
+			if val, _ := mgr.GetValue("span"); val != "2.1" {
+				t.Fatalf("expected a derived span from Attach, got %v", val)
+			}
+		})
+	})
+}
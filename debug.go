@@ -0,0 +1,274 @@
+package gls
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// historySize is how many SetValues calls History remembers per goroutine.
+const historySize = 32
+
+// SetRecord describes a SetValues call, as returned by History.
+type SetRecord struct {
+	// Keys are the keys the call set, in no particular order.
+	Keys []interface{}
+	// File and Line locate the call: the innermost frame outside of this
+	// package, so calls through helpers such as SetValuesFrom or Scope
+	// report their caller. File is empty for calls gls makes on its own
+	// behalf, such as Go re-establishing values on a new goroutine.
+	File string
+	Line int
+}
+
+// setHistory is a ring buffer of the most recent SetRecords.
+type setHistory struct {
+	records []SetRecord
+	next    int
+}
+
+func (h *setHistory) add(r SetRecord) {
+	if len(h.records) < historySize {
+		h.records = append(h.records, r)
+		return
+	}
+	h.records[h.next] = r
+	h.next = (h.next + 1) % historySize
+}
+
+// History returns the most recent SetValues calls made on this
+// ContextManager on the current goroutine, oldest first, for working out
+// where a surprising value came from. It only records anything if the
+// ContextManager was created with Option.Debug, and, like AppendError, it
+// only remembers calls made since the outermost scope on the goroutine was
+// entered. Calls on other goroutines, including parents a goroutine
+// inherited its values from, are not included.
+func (m *ContextManager) History() []SetRecord {
+	meta := m.currentMeta(false)
+	if meta == nil {
+		return nil
+	}
+	h := &meta.history
+	return append(append([]SetRecord(nil), h.records[h.next:]...),
+		h.records[:h.next]...)
+}
+
+// debugFrame is what Option.Debug remembers about an active SetValues call.
+type debugFrame struct {
+	passed   Values // the map SetValues was passed
+	contents Values // a copy of it as it was then
+	file     string
+	line     int
+	// keys are the keys the call set, and reads how often GetValue had
+	// found each of them by then
+	keys  []interface{}
+	reads []uint64
+	scope *debugScope
+}
+
+// debugScope records where a SetValues scope was entered, and whether it has
+// exited, for goroutines started by Go that inherit its values.
+type debugScope struct {
+	pcs    []uintptr
+	exited int32
+}
+
+// debugEnter does Option.Debug's bookkeeping for a SetValues call on gid that
+// was passed the map passed and set values, which differ if keys were
+// normalized. Besides recording the call for History, it reports a map
+// passed again to a nested call after being modified, a sign of code
+// expecting changes to a map to show through values already set, and
+// remembers the read counts debugExit checks for keys that were never read.
+func (m *ContextManager) debugEnter(gid uint32, passed, values Values) {
+	meta := m.getMeta(gid, true)
+	if meta == nil {
+		return
+	}
+	record := SetRecord{Keys: make([]interface{}, 0, len(values))}
+	for key := range values {
+		record.Keys = append(record.Keys, key)
+	}
+	record.File, record.Line = externalCaller()
+	meta.history.add(record)
+
+	for _, frame := range meta.frames {
+		if sameMap(frame.passed, passed) && !sameContents(frame.contents, passed) {
+			logf("gls: Values map passed to SetValues at %s:%d was modified "+
+				"since it was passed to SetValues at %s:%d; the changes did "+
+				"not affect the values set then", record.File, record.Line,
+				frame.file, frame.line)
+			break
+		}
+	}
+	contents := make(Values, len(passed))
+	for key, val := range passed {
+		contents[key] = val
+	}
+	reads := make([]uint64, len(record.Keys))
+	for i, key := range record.Keys {
+		reads[i] = meta.reads[key]
+	}
+	meta.frames = append(meta.frames, debugFrame{passed: passed,
+		contents: contents, file: record.File, line: record.Line,
+		keys: record.Keys, reads: reads,
+		scope: &debugScope{pcs: callers()}})
+}
+
+// debugRead counts a GetValue on gid that found key, for debugExit.
+func (m *ContextManager) debugRead(gid uint32, key interface{}) {
+	meta := m.getMeta(gid, false)
+	if meta == nil {
+		return
+	}
+	if meta.reads == nil {
+		meta.reads = make(map[interface{}]uint64)
+	}
+	meta.reads[key]++
+}
+
+// debugExit undoes debugEnter as a SetValues call on gid returns, reporting
+// if the map it was passed was modified while it ran, and any keys it set
+// that GetValue never found while it ran. Reads are counted by key, so a read
+// of a nested scope's value for the same key counts for the outer one too.
+// Values gls sets on its own behalf, as Go does, are not reported, as a
+// goroutine needn't read everything it inherits.
+func (m *ContextManager) debugExit(gid uint32) {
+	meta := m.getMeta(gid, false)
+	if meta == nil || len(meta.frames) == 0 {
+		return
+	}
+	frame := meta.frames[len(meta.frames)-1]
+	meta.frames = meta.frames[:len(meta.frames)-1]
+	atomic.StoreInt32(&frame.scope.exited, 1)
+	if !sameContents(frame.contents, frame.passed) {
+		logf("gls: Values map passed to SetValues at %s:%d was modified "+
+			"while its scope was active; the changes had no effect",
+			frame.file, frame.line)
+	}
+	if frame.file == "" {
+		return
+	}
+	var unread []interface{}
+	for i, key := range frame.keys {
+		if meta.reads[key] == frame.reads[i] {
+			unread = append(unread, key)
+		}
+	}
+	if len(unread) > 0 {
+		sortKeys(unread, nil)
+		logf("gls: keys %v set by SetValues at %s:%d were never read by "+
+			"GetValue", unread, frame.file, frame.line)
+	}
+}
+
+func sameMap(a, b Values) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func sameContents(a, b Values) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, aVal := range a {
+		if bVal, ok := b[key]; !ok || !sameValue(aVal, bVal) {
+			return false
+		}
+	}
+	return true
+}
+
+// glsPackage is this package's import path, as it prefixes function names.
+var glsPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")]
+}()
+
+// externalCaller returns the location of the innermost frame on the stack
+// outside of this package and the runtime, not counting this package's tests,
+// or an empty file if there is none.
+func externalCaller() (file string, line int) {
+	// the stack tag alone can be dozens of frames deep, so walk the stack
+	// in batches
+	var pcs [32]uintptr
+	for skip := 2; ; {
+		n := runtime.Callers(skip, pcs[:])
+		if n == 0 {
+			return "", 0
+		}
+		frames := runtime.CallersFrames(pcs[:n])
+		for more := true; more; {
+			var frame runtime.Frame
+			frame, more = frames.Next()
+			if strings.HasPrefix(frame.Function, "runtime.") {
+				continue
+			}
+			if !strings.HasPrefix(frame.Function, glsPackage+".") ||
+				strings.HasSuffix(frame.File, "_test.go") {
+				return frame.File, frame.Line
+			}
+		}
+		skip += n
+	}
+}
+
+// debugGoCheck returns a function for a goroutine started by Go with
+// snapshots to call as it starts, which reports if any scope it inherited
+// values from on a ContextManager with Option.Debug has exited by then: the
+// child was handed a context its parent has already left, which is often
+// why values seem to disappear. It returns nil if there is nothing to check.
+func debugGoCheck(snapshots []mgrSnapshot) func() {
+	var scopes []*debugScope
+	for _, snap := range snapshots {
+		if !snap.mgr.debug {
+			continue
+		}
+		if meta := snap.mgr.currentMeta(false); meta != nil &&
+			len(meta.frames) > 0 {
+			scopes = append(scopes, meta.frames[len(meta.frames)-1].scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+	goPCs := callers()
+	return func() {
+		for _, scope := range scopes {
+			if atomic.LoadInt32(&scope.exited) != 0 {
+				logf("gls: goroutine started by Go at\n%sbegan running after "+
+					"the SetValues scope it inherited values from had exited; "+
+					"the scope was entered at\n%s", formatStack(goPCs),
+					formatStack(scope.pcs))
+			}
+		}
+	}
+}
+
+// callers returns the program counters of the current goroutine's stack,
+// starting with callers' caller.
+func callers() []uintptr {
+	pcs := make([]uintptr, 128)
+	return pcs[:runtime.Callers(3, pcs)]
+}
+
+// formatStack formats pcs one frame per line, leaving out the runtime and
+// this package's own frames, other than its tests.
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for more := true; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") ||
+			strings.HasPrefix(frame.Function, glsPackage+".") &&
+				!strings.HasSuffix(frame.File, "_test.go") {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File,
+			frame.Line)
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package gls
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	mgr := NewContextManager(Option{Debug: true})
+	defer mgr.Unregister()
+	// keys set here are never read
+	defer captureLogs()()
+
+	line := func() int {
+		_, _, line, _ := runtime.Caller(1)
+		return line
+	}
+
+	var outerLine, innerLine int
+	outerLine = line() + 1
+	mgr.SetValues(Values{"outer": 1}, func() {
+		innerLine = line() + 1
+		mgr.SetValuesFrom(Values{"a": 1}, Values{"b": 2}, func() {
+			history := mgr.History()
+			if len(history) != 2 {
+				t.Fatalf("expected 2 records, got %d", len(history))
+			}
+			if history[0].Line != outerLine || history[1].Line != innerLine {
+				t.Fatalf("expected records from lines %d and %d, got %d and %d",
+					outerLine, innerLine, history[0].Line, history[1].Line)
+			}
+			if len(history[1].Keys) != 2 {
+				t.Fatalf("expected 2 keys in the inner record, got %v",
+					history[1].Keys)
+			}
+
+			done := make(chan []SetRecord)
+			Go(func() { done <- mgr.History() })
+			if inherited := <-done; len(inherited) != 1 ||
+				inherited[0].File != "" {
+				t.Fatalf("expected a single record without a file for Go, got %v",
+					inherited)
+			}
+		})
+
+		for i := 0; i < historySize+5; i++ {
+			mgr.SetValues(Values{"loop": i}, func() {})
+		}
+		history := mgr.History()
+		if len(history) != historySize {
+			t.Fatalf("expected %d records, got %d", historySize, len(history))
+		}
+		if history[len(history)-1].Keys[0] != "loop" ||
+			history[0].Keys[0] != "loop" {
+			t.Fatalf("expected the oldest records to be dropped")
+		}
+	})
+
+	plain := NewContextManager(Option{})
+	defer plain.Unregister()
+	plain.SetValues(Values{"key": "val"}, func() {
+		if history := plain.History(); history != nil {
+			t.Fatalf("expected no history without Debug, got %v", history)
+		}
+	})
+}
+
+func TestDebugAliasing(t *testing.T) {
+	mgr := NewContextManager(Option{Debug: true})
+	defer mgr.Unregister()
+
+	stop := captureLogs()
+	shared := Values{"user": "alice"}
+	read := func() { mgr.GetValue("user") }
+	mgr.SetValues(shared, func() {
+		mgr.SetValues(shared, read)
+		shared["user"] = "bob"
+		mgr.SetValues(shared, read)
+	})
+	logs := stop()
+
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", logs)
+	}
+	if !strings.Contains(logs[0], "since it was passed to SetValues at") ||
+		!strings.Contains(logs[0], "debug_test.go") {
+		t.Fatalf("expected a warning about the nested call, got %q", logs[0])
+	}
+	if !strings.Contains(logs[1], "while its scope was active") {
+		t.Fatalf("expected a warning about the outer scope, got %q", logs[1])
+	}
+
+	stop = captureLogs()
+	plain := NewContextManager(Option{})
+	defer plain.Unregister()
+	plain.SetValues(shared, func() { shared["user"] = "carol" })
+	if logs := stop(); len(logs) != 0 {
+		t.Fatalf("expected no warnings without Debug, got %v", logs)
+	}
+}
+
+func TestDebugUnread(t *testing.T) {
+	mgr := NewContextManager(Option{Debug: true})
+	defer mgr.Unregister()
+
+	stop := captureLogs()
+	mgr.SetValues(Values{"used": 1, "unused": 2, "nested": 3}, func() {
+		mgr.GetValue("used")
+		mgr.SetValues(Values{"nested": 4}, func() {
+			mgr.GetValue("nested")
+		})
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			mgr.GetValue("used")
+		})
+		<-done
+	})
+	logs := stop()
+
+	if len(logs) != 1 || !strings.Contains(logs[0], "[unused]") ||
+		!strings.Contains(logs[0], "debug_test.go") {
+		t.Fatalf("expected a single warning about unused, got %v", logs)
+	}
+
+	stop = captureLogs()
+	plain := NewContextManager(Option{})
+	defer plain.Unregister()
+	plain.SetValues(Values{"unused": 1}, func() {})
+	if logs := stop(); len(logs) != 0 {
+		t.Fatalf("expected no warnings without Debug, got %v", logs)
+	}
+}
+
+func TestDebugGoAfterScope(t *testing.T) {
+	mgr := NewContextManager(Option{Debug: true})
+	defer mgr.Unregister()
+
+	stop := captureLogs()
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	mgr.SetValues(Values{"request": "1"}, func() {
+		mgr.GetValue("request")
+		Go(func() {
+			defer close(done)
+			<-exited
+			mgr.GetValue("request")
+		})
+	})
+	close(exited)
+	<-done
+	logs := stop()
+	if len(logs) != 1 || !strings.Contains(logs[0], "had exited") ||
+		!strings.Contains(logs[0], "TestDebugGoAfterScope") {
+		t.Fatalf("expected a warning with both stacks, got %v", logs)
+	}
+
+	stop = captureLogs()
+	mgr.SetValues(Values{"request": "2"}, func() {
+		mgr.GetValue("request")
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			mgr.GetValue("request")
+		})
+		<-done
+	})
+	if logs := stop(); len(logs) != 0 {
+		t.Fatalf("expected no warning while the scope is active, got %v", logs)
+	}
+}
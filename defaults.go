@@ -0,0 +1,31 @@
+package gls
+
+// SetDefaults sets process-wide defaults for this ContextManager: GetValue
+// returns the value d has for a key whenever the key isn't set on the current
+// goroutine. Values set on the goroutine always take precedence over
+// defaults, and defaults take precedence over a DefaultedKey's own default.
+// It suits config-like values that are set once, at startup, and only
+// occasionally overridden for a goroutine.
+//
+// d is copied, so later changes to it have no effect; call SetDefaults again
+// to replace the defaults wholesale, or with nil to remove them. The values
+// themselves are shared by every goroutine, so they must not be modified,
+// even through GetValueForWrite. Defaults are not part of Snapshot, and are
+// not propagated by Go, as every goroutine sees them anyway.
+func (m *ContextManager) SetDefaults(d Values) {
+	defaults := make(Values, len(d))
+	for key, val := range d {
+		defaults[m.normalizeKey(key)] = val
+	}
+	m.defaults.Store(defaults)
+}
+
+// missing is what GetValue returns for a key that isn't set.
+func (m *ContextManager) missing(key interface{}) (value interface{}, ok bool) {
+	if defaults, _ := m.defaults.Load().(Values); len(defaults) > 0 {
+		if value, ok = defaults[m.normalizeKey(key)]; ok {
+			return value, true
+		}
+	}
+	return missingValue(key)
+}
@@ -0,0 +1,38 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestSetDefaults(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	key := NewDefaultedKey("key default")
+	d := Values{"region": "us", key: "manager default"}
+	mgr.SetDefaults(d)
+	d["region"] = "changed"
+
+	check := func(key interface{}, expected interface{}, expectedOk bool) {
+		if val, ok := mgr.GetValue(key); val != expected || ok != expectedOk {
+			t.Fatalf("expected %v (%v) for key %v, got %v (%v)", expected,
+				expectedOk, key, val, ok)
+		}
+	}
+
+	check("region", "us", true)
+	check(key, "manager default", true)
+	check("missing", nil, false)
+	mgr.SetValues(Values{"region": "eu"}, func() {
+		check("region", "eu", true)
+		check("missing", nil, false)
+		if snapshot := mgr.Snapshot(); len(snapshot) != 1 {
+			t.Fatalf("expected defaults to be left out of snapshots, got %v",
+				snapshot)
+		}
+	})
+
+	mgr.SetDefaults(nil)
+	check("region", nil, false)
+	check(key, "key default", true)
+}
@@ -0,0 +1,64 @@
+package gls
+
+// closedDone is what Done returns outside of any scope.
+var closedDone = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Done returns a channel that is closed when the innermost SetValues scope
+// of this ContextManager on the current goroutine exits, whether normally or
+// by a panic. External code can select on it to release resources tied to
+// that scope, much like context.Context.Done, but bound to a gls scope
+// rather than a context. Scopes nested inside it have channels of their own,
+// so a given channel is closed by the exit of the scope that was innermost
+// when Done was called, not of whatever scope is innermost when it fires.
+//
+// Channels are only made when Done is called, so scopes that don't use it
+// pay nothing for it. Outside of any scope, Done returns a channel that is
+// already closed. A goroutine started with Go gets a fresh scope of its own,
+// whose channel is closed when that goroutine's callback returns.
+func (m *ContextManager) Done() <-chan struct{} {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return closedDone
+	}
+	m.extendLock.RLock()
+	defer m.extendLock.RUnlock()
+	if gid >= uint32(len(m.values)) || m.values[gid] == nil ||
+		m.depths[gid] == 0 {
+		return closedDone
+	}
+	meta := m.meta[gid]
+	if meta == nil {
+		meta = &goroutineMeta{}
+		m.meta[gid] = meta
+	}
+	depth := m.depths[gid]
+	if meta.done == nil {
+		meta.done = make(map[uint32]chan struct{})
+	}
+	ch := meta.done[depth]
+	if ch == nil {
+		ch = make(chan struct{})
+		meta.done[depth] = ch
+	}
+	return ch
+}
+
+// closeDone closes the Done channel for the scope at depth, if there is one.
+func (meta *goroutineMeta) closeDone(depth uint32) {
+	if ch := meta.done[depth]; ch != nil {
+		close(ch)
+		delete(meta.done, depth)
+	}
+}
+
+// closeAllDone closes every outstanding Done channel.
+func (meta *goroutineMeta) closeAllDone() {
+	for depth, ch := range meta.done {
+		close(ch)
+		delete(meta.done, depth)
+	}
+}
@@ -0,0 +1,66 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestDone(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	isClosed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if !isClosed(mgr.Done()) {
+		t.Fatalf("expected a closed channel outside of a scope")
+	}
+
+	var outer, inner <-chan struct{}
+	mgr.SetValues(Values{"key": "outer"}, func() {
+		outer = mgr.Done()
+		if mgr.Done() != outer {
+			t.Fatalf("expected the same channel within a scope")
+		}
+		mgr.SetValues(Values{"key": "inner"}, func() {
+			inner = mgr.Done()
+			if inner == outer {
+				t.Fatalf("expected a nested scope to have its own channel")
+			}
+		})
+		if !isClosed(inner) {
+			t.Fatalf("expected inner channel to be closed on inner scope exit")
+		}
+		if isClosed(outer) {
+			t.Fatalf("expected outer channel to stay open in the outer scope")
+		}
+
+		done := make(chan (<-chan struct{}))
+		Go(func() { done <- mgr.Done() })
+		if child := <-done; child == outer {
+			t.Fatalf("expected child goroutine to get its own channel")
+		}
+	})
+	if !isClosed(outer) {
+		t.Fatalf("expected outer channel to be closed on outer scope exit")
+	}
+
+	func() {
+		defer func() { recover() }()
+		mgr.SetValues(Values{"key": "outer"}, func() {
+			outer = mgr.Done()
+			mgr.SetValues(Values{"key": "inner"}, func() {
+				inner = mgr.Done()
+				panic("boom")
+			})
+		})
+	}()
+	if !isClosed(inner) || !isClosed(outer) {
+		t.Fatalf("expected channels to be closed when a panic exits the scopes")
+	}
+}
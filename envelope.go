@@ -0,0 +1,26 @@
+package gls
+
+// Envelope is the typed counterpart of Job: it carries an item together with
+// the values every registered ContextManager had on the goroutine that
+// created it, for handing work to long-lived goroutines over a channel.
+type Envelope[T any] struct {
+	job Job
+}
+
+// NewEnvelope returns an Envelope holding item and a copy of the current
+// goroutine's values, taken the same way Go takes them.
+func NewEnvelope[T any](item T) Envelope[T] {
+	return Envelope[T]{job: NewJob(item)}
+}
+
+// Item returns the item e carries, without setting any values.
+func (e Envelope[T]) Item() T {
+	item, _ := e.job.Payload().(T)
+	return item
+}
+
+// Run calls fn with e's item and with the values e captured set, as Job.Run
+// does. The zero Envelope sets nothing.
+func (e Envelope[T]) Run(fn func(item T)) {
+	e.job.Run(func(interface{}) { fn(e.Item()) })
+}
@@ -0,0 +1,41 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	work := make(chan Envelope[int])
+	results := make(chan interface{})
+	go func() {
+		for e := range work {
+			e.Run(func(item int) {
+				val, _ := mgr.GetValue("request")
+				results <- [2]interface{}{item, val}
+			})
+			if _, ok := mgr.GetValue("request"); ok {
+				t.Errorf("expected no values after Run returns")
+			}
+		}
+		close(results)
+	}()
+
+	for i, request := range []string{"first", "second"} {
+		mgr.SetValues(Values{"request": request}, func() {
+			work <- NewEnvelope(i)
+		})
+		expected := [2]interface{}{i, request}
+		if got := <-results; got != expected {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	}
+	work <- Envelope[int]{}
+	if got := <-results; got != [2]interface{}{0, nil} {
+		t.Fatalf("expected zero Envelope to set nothing, got %v", got)
+	}
+	close(work)
+	<-results
+}
@@ -0,0 +1,29 @@
+package gls
+
+// AppendError records a non-fatal error for the current goroutine, to be
+// retrieved later with Errors, for instance by middleware logging everything
+// that went wrong once a request handler returns. Errors are kept until the
+// outermost SetValues scope of this ContextManager on the current goroutine
+// exits. AppendError does nothing if there is no such scope.
+//
+// Errors are local to the goroutine that recorded them. They are not
+// propagated by Go; a goroutine started by Go starts with no errors, and
+// errors it records are not visible to its parent.
+func (m *ContextManager) AppendError(err error) {
+	if err == nil {
+		return
+	}
+	if meta := m.currentMeta(true); meta != nil {
+		meta.errors = append(meta.errors, err)
+	}
+}
+
+// Errors returns the errors recorded with AppendError on the current
+// goroutine, in the order they were recorded.
+func (m *ContextManager) Errors() []error {
+	meta := m.currentMeta(false)
+	if meta == nil || len(meta.errors) == 0 {
+		return nil
+	}
+	return append([]error(nil), meta.errors...)
+}
@@ -0,0 +1,50 @@
+package gls
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAppendError(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	errA := errors.New("a")
+	errB := errors.New("b")
+
+	mgr.AppendError(errA)
+	if errs := mgr.Errors(); errs != nil {
+		t.Fatalf("expected no errors outside of SetValues, got %v", errs)
+	}
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		mgr.AppendError(errA)
+		mgr.SetValues(Values{"key2": "val2"}, func() {
+			mgr.AppendError(errB)
+			mgr.AppendError(nil)
+		})
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		Go(func() {
+			defer wg.Done()
+			if errs := mgr.Errors(); errs != nil {
+				t.Errorf("expected no errors to propagate, got %v", errs)
+			}
+			mgr.AppendError(errors.New("child"))
+		})
+		wg.Wait()
+
+		errs := mgr.Errors()
+		if len(errs) != 2 || errs[0] != errA || errs[1] != errB {
+			t.Fatalf("expected errors [a b], got %v", errs)
+		}
+	})
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		if errs := mgr.Errors(); errs != nil {
+			t.Fatalf("expected errors to be cleared with scope, got %v", errs)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package gls
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ExportJSON returns every goroutine's values on this ContextManager as a JSON
+// object keyed by goroutine identifier, for debugging endpoints such as
+// /debug/gls. Each goroutine maps to an object of its string-keyed values;
+// values under other key types are left out. A value that can't be encoded as
+// JSON is represented by its Go type, such as "chan int", rather than failing
+// the export, and a value set by SetLazy that has not been computed yet is
+// left out.
+//
+// Like RangeActive, ExportJSON is a diagnostic. It copies every goroutine's
+// values at once, blocking SetValues calls on this ContextManager while it
+// does so, and encodes them afterwards without holding any locks. Values are
+// copied shallowly, so one changed in place while it is encoded, such as a
+// map, may be encoded mid-change.
+func (m *ContextManager) ExportJSON() ([]byte, error) {
+	snapshot := make(map[string]map[string]interface{})
+	m.extendLock.Lock()
+	for gid, state := range m.values {
+		var values map[string]interface{}
+		for key, val := range state {
+			skey, ok := key.(string)
+			if !ok {
+				continue
+			}
+			if _, lazy := val.(*lazyValue); lazy {
+				continue
+			}
+			if values == nil {
+				values = make(map[string]interface{}, len(state))
+			}
+			values[skey] = val
+		}
+		if values != nil {
+			snapshot[strconv.Itoa(gid)] = values
+		}
+	}
+	m.extendLock.Unlock()
+
+	encoded := make(map[string]map[string]json.RawMessage, len(snapshot))
+	for gid, values := range snapshot {
+		out := make(map[string]json.RawMessage, len(values))
+		for key, val := range values {
+			val = peekValue(val)
+			raw, err := json.Marshal(val)
+			if err != nil {
+				raw, _ = json.Marshal(fmt.Sprintf("%T", val))
+			}
+			out[key] = raw
+		}
+		encoded[gid] = out
+	}
+	return json.Marshal(encoded)
+}
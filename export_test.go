@@ -0,0 +1,58 @@
+package gls
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestExportJSON(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	values := Values{
+		"str":    "val",
+		"num":    3,
+		"list":   []string{"a", "b"},
+		"ch":     make(chan int),
+		"fn":     func() {},
+		GenSym(): "hidden",
+	}
+	mgr.SetValues(values, func() {
+		mgr.SetLazy("lazy", func() interface{} { return "computed" }, func() {
+			data, err := mgr.ExportJSON()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var exported map[string]map[string]interface{}
+			if err := json.Unmarshal(data, &exported); err != nil {
+				t.Fatalf("expected valid JSON, got %v: %s", err, data)
+			}
+
+			gid, _ := GetGoroutineId()
+			got := exported[strconv.Itoa(int(gid))]
+			expected := map[string]interface{}{
+				"str": "val",
+				"num": float64(3),
+				"ch":  "chan int",
+				"fn":  "func()",
+			}
+			if len(got) != len(expected)+1 {
+				t.Fatalf("expected %d values, got %v", len(expected)+1, got)
+			}
+			for key, exp := range expected {
+				if got[key] != exp {
+					t.Fatalf("expected %v for %s, got %v", exp, key, got[key])
+				}
+			}
+			if list, _ := got["list"].([]interface{}); len(list) != 2 {
+				t.Fatalf("expected list to be exported, got %v", got["list"])
+			}
+		})
+	})
+
+	data, err := mgr.ExportJSON()
+	if err != nil || string(data) != "{}" {
+		t.Fatalf("expected an empty export, got %s, %v", data, err)
+	}
+}
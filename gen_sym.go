@@ -19,3 +19,37 @@ func GenSym() ContextKey {
 	keyCounter += 1
 	return ContextKey{id: keyCounter}
 }
+
+// DefaultedKey is a key that carries its own default value. GetValue returns
+// the default, with ok set to true, whenever a DefaultedKey is not set,
+// instead of reporting it as missing. This co-locates a key's default with
+// its definition rather than at every call site. Use NewDefaultedKey for
+// construction.
+type DefaultedKey struct {
+	key ContextKey
+	def *keyDefault
+}
+
+type keyDefault struct{ value interface{} }
+
+// NewDefaultedKey returns a brand new, never-before-used DefaultedKey whose
+// default value is defaultValue.
+func NewDefaultedKey(defaultValue interface{}) DefaultedKey {
+	return DefaultedKey{key: GenSym(), def: &keyDefault{value: defaultValue}}
+}
+
+// Default returns the key's default value.
+func (k DefaultedKey) Default() interface{} {
+	if k.def == nil {
+		return nil
+	}
+	return k.def.value
+}
+
+// missingValue is what GetValue returns for a key that isn't set.
+func missingValue(key interface{}) (value interface{}, ok bool) {
+	if k, isDefaulted := key.(DefaultedKey); isDefaulted && k.def != nil {
+		return k.def.value, true
+	}
+	return nil, false
+}
@@ -0,0 +1,35 @@
+package gls
+
+import (
+	"sync/atomic"
+)
+
+// Generation returns a counter that changes whenever the current goroutine's
+// values on this ContextManager change, whether by a SetValues scope starting
+// or exiting, SwapValues, or WithValue. Code that caches something derived
+// from the current values can remember the generation it computed it at and
+// recompute once Generation returns something else.
+//
+// The counter is per goroutine, not global: changes on other goroutines do not
+// affect it, and comparing generations from different goroutines is
+// meaningless. It only ever increases. It is kept per goroutine identifier,
+// and identifiers are reused, so a new goroutine may start with a non-zero
+// generation left by an earlier one with the same identifier; only an
+// identifier no goroutine has ever set values with has generation zero.
+func (m *ContextManager) Generation() uint64 {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return 0
+	}
+	m.extendLock.RLock()
+	defer m.extendLock.RUnlock()
+	if gid >= uint32(len(m.generations)) {
+		return 0
+	}
+	return atomic.LoadUint64(&m.generations[gid])
+}
+
+// bumpGeneration records a change to gid's values. extendLock must be held.
+func (m *ContextManager) bumpGeneration(gid uint32) {
+	atomic.AddUint64(&m.generations[gid], 1)
+}
@@ -0,0 +1,61 @@
+package gls
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGeneration(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	other := NewContextManager(Option{})
+	defer other.Unregister()
+
+	if gen := mgr.Generation(); gen != 0 {
+		t.Fatalf("expected generation 0 without an identifier, got %d", gen)
+	}
+
+	other.SetValues(Values{"key": "val"}, func() {
+		start := mgr.Generation()
+		last := start
+		changed := func(what string) {
+			gen := mgr.Generation()
+			if gen <= last {
+				t.Fatalf("expected generation to increase after %s, got %d <= %d",
+					what, gen, last)
+			}
+			last = gen
+		}
+		unchanged := func(what string) {
+			if gen := mgr.Generation(); gen != last {
+				t.Fatalf("expected generation %d to be unchanged after %s, got %d",
+					last, what, gen)
+			}
+		}
+
+		other.SetValues(Values{"key": "val2"}, func() {})
+		unchanged("another manager's SetValues")
+
+		mgr.SetValues(Values{"key": "val"}, func() {
+			changed("SetValues")
+			mgr.GetValue("key")
+			unchanged("GetValue")
+			mgr.SetValues(Values{"key": "val2"}, func() {
+				changed("nested SetValues")
+			})
+			changed("nested scope exit")
+
+			DefaultManager.SetValues(Values{"x": 1}, func() {
+				mgr.Generation()
+				WithValue(context.Background(), "y", 2)
+			})
+			unchanged("WithValue on another manager")
+
+			old := mgr.SwapValues(Values{"key": "swapped"})
+			changed("SwapValues")
+			mgr.SwapValues(old)
+			changed("SwapValues back")
+		})
+		changed("scope exit")
+	})
+}
@@ -0,0 +1,60 @@
+package gls
+
+// GetFirst looks up key on each of mgrs in turn and returns the value from the
+// first ContextManager that has it set for the current goroutine. This encodes
+// a precedence between managers, e.g. request overrides before session values
+// before global defaults. If none has the key, ok will be false.
+func GetFirst(key interface{}, mgrs ...*ContextManager) (
+	value interface{}, ok bool) {
+	for _, mgr := range mgrs {
+		if value, ok = mgr.GetValue(key); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// GetValueString returns a previously set value as a string. ok will be false
+// if the value is not found or is not a string.
+func (m *ContextManager) GetValueString(key interface{}) (value string, ok bool) {
+	raw, found := m.GetValue(key)
+	if !found {
+		return "", false
+	}
+	value, ok = raw.(string)
+	return value, ok
+}
+
+// GetValueInt returns a previously set value as an int. ok will be false if
+// the value is not found or is not an int. No numeric conversion is
+// performed, so an int64 value will not be returned.
+func (m *ContextManager) GetValueInt(key interface{}) (value int, ok bool) {
+	raw, found := m.GetValue(key)
+	if !found {
+		return 0, false
+	}
+	value, ok = raw.(int)
+	return value, ok
+}
+
+// GetValueBool returns a previously set value as a bool. ok will be false if
+// the value is not found or is not a bool.
+func (m *ContextManager) GetValueBool(key interface{}) (value bool, ok bool) {
+	raw, found := m.GetValue(key)
+	if !found {
+		return false, false
+	}
+	value, ok = raw.(bool)
+	return value, ok
+}
+
+// GetValueFloat64 returns a previously set value as a float64. ok will be
+// false if the value is not found or is not a float64.
+func (m *ContextManager) GetValueFloat64(key interface{}) (value float64, ok bool) {
+	raw, found := m.GetValue(key)
+	if !found {
+		return 0, false
+	}
+	value, ok = raw.(float64)
+	return value, ok
+}
@@ -0,0 +1,136 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestTypedGetters(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if _, ok := mgr.GetValueString("str"); ok {
+		t.Fatalf("expected no string value outside of SetValues")
+	}
+
+	mgr.SetValues(Values{
+		"str":   "hello",
+		"int":   42,
+		"bool":  true,
+		"float": 1.5,
+	}, func() {
+		if val, ok := mgr.GetValueString("str"); !ok || val != "hello" {
+			t.Fatalf("expected string hello, got %q (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueInt("int"); !ok || val != 42 {
+			t.Fatalf("expected int 42, got %d (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueBool("bool"); !ok || !val {
+			t.Fatalf("expected bool true, got %v (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueFloat64("float"); !ok || val != 1.5 {
+			t.Fatalf("expected float 1.5, got %v (%v)", val, ok)
+		}
+
+		// mismatched types
+		if val, ok := mgr.GetValueString("int"); ok || val != "" {
+			t.Fatalf("expected string mismatch, got %q (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueInt("float"); ok || val != 0 {
+			t.Fatalf("expected int mismatch, got %d (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueBool("str"); ok || val {
+			t.Fatalf("expected bool mismatch, got %v (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueFloat64("int"); ok || val != 0 {
+			t.Fatalf("expected float mismatch, got %v (%v)", val, ok)
+		}
+
+		// absent keys
+		if _, ok := mgr.GetValueString("missing"); ok {
+			t.Fatalf("expected missing string value")
+		}
+		if _, ok := mgr.GetValueInt("missing"); ok {
+			t.Fatalf("expected missing int value")
+		}
+		if _, ok := mgr.GetValueBool("missing"); ok {
+			t.Fatalf("expected missing bool value")
+		}
+		if _, ok := mgr.GetValueFloat64("missing"); ok {
+			t.Fatalf("expected missing float value")
+		}
+	})
+}
+
+func TestGetFirst(t *testing.T) {
+	request := NewContextManager(Option{})
+	defer request.Unregister()
+	session := NewContextManager(Option{})
+	defer session.Unregister()
+	global := NewContextManager(Option{})
+	defer global.Unregister()
+
+	check := func(key string, exp interface{}) {
+		val, ok := GetFirst(key, request, session, global)
+		if exp == nil {
+			if ok {
+				t.Fatalf("expected no value for key %s, got %v", key, val)
+			}
+			return
+		}
+		if !ok || val != exp {
+			t.Fatalf("expected value %v for key %s, got %v", exp, key, val)
+		}
+	}
+
+	check("lang", nil)
+	global.SetValues(Values{"lang": "en", "tz": "UTC"}, func() {
+		session.SetValues(Values{"lang": "de", "user": "bob"}, func() {
+			check("tz", "UTC")
+			check("lang", "de")
+			check("user", "bob")
+			request.SetValues(Values{"lang": "fr"}, func() {
+				check("lang", "fr")
+				check("user", "bob")
+				check("missing", nil)
+			})
+			check("lang", "de")
+		})
+		check("lang", "en")
+	})
+	if _, ok := GetFirst("lang"); ok {
+		t.Fatalf("expected no value with no managers")
+	}
+}
+
+func TestDefaultedKey(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	key := NewDefaultedKey("anonymous")
+	other := NewDefaultedKey("anonymous")
+	if key == other {
+		t.Fatalf("expected distinct defaulted keys")
+	}
+	if key.Default() != "anonymous" {
+		t.Fatalf("expected default anonymous, got %v", key.Default())
+	}
+
+	if val, ok := mgr.GetValue(key); !ok || val != "anonymous" {
+		t.Fatalf("expected default outside of SetValues, got %v (%v)", val, ok)
+	}
+	mgr.SetValues(Values{key: "bob"}, func() {
+		if val, ok := mgr.GetValue(key); !ok || val != "bob" {
+			t.Fatalf("expected set value bob, got %v (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValue(other); !ok || val != "anonymous" {
+			t.Fatalf("expected default for unset key, got %v (%v)", val, ok)
+		}
+		if val, ok := mgr.GetValueString(other); !ok || val != "anonymous" {
+			t.Fatalf("expected typed default, got %v (%v)", val, ok)
+		}
+	})
+
+	if _, ok := mgr.GetValue(DefaultedKey{}); ok {
+		t.Fatalf("expected zero DefaultedKey to have no default")
+	}
+}
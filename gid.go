@@ -1,31 +1,131 @@
 package gls
 
 import (
-	"golang.design/x/lockfree"
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
 )
 
 var (
-	stackTagPool = &idPool{
-		queue: lockfree.NewQueue(),
-	}
+	stackTagPool = newIDPool()
+	idFunc       atomic.Value // func() (uint32, bool)
+
+	// ErrIDExhausted is returned when a goroutine needs an identifier but
+	// every identifier is already in use by another goroutine.
+	ErrIDExhausted = errors.New("gls: goroutine identifiers exhausted")
 )
 
 // Will return this goroutine's identifier if set. If you always need a
 // goroutine identifier, you should use EnsureGoroutineId which will make one
 // if there isn't one already.
 func GetGoroutineId() (gid uint32, ok bool) {
+	if gid, ok := customGoroutineId(); ok {
+		return gid, true
+	}
 	return readStackTag()
 }
 
 // Will call cb with the current goroutine identifier. If one hasn't already
 // been generated, one will be created and set first. The goroutine identifier
-// might be invalid after cb returns.
+// might be invalid after cb returns. Panics with ErrIDExhausted if no
+// identifier is available.
 func EnsureGoroutineId(cb func(gid uint32)) {
-	if gid, ok := readStackTag(); ok {
+	if err := ensureGoroutineId(cb); err != nil {
+		panic(err)
+	}
+}
+
+func ensureGoroutineId(cb func(gid uint32)) error {
+	if gid, ok := GetGoroutineId(); ok {
 		cb(gid)
-		return
+		return nil
+	}
+	gid, err := stackTagPool.Acquire()
+	if err != nil {
+		return err
 	}
-	gid := stackTagPool.Acquire()
 	defer stackTagPool.Release(gid)
 	addStackTag(gid, func() { cb(gid) })
+	return nil
+}
+
+// SetIDEventHook registers fn to be called whenever a goroutine identifier is
+// handed out. reused is false when the identifier was freshly allocated and
+// true when it was recycled from a goroutine that no longer needs it. The hook
+// is global, affects all identifier allocation in the process, and is called
+// on the allocating goroutine, so it should be cheap. Passing nil removes the
+// hook.
+func SetIDEventHook(fn func(id uint32, reused bool)) {
+	stackTagPool.setHook(fn)
+}
+
+// SetAcquireHook registers fn to be called whenever a goroutine identifier is
+// handed out, with how long getting it took and whether it was recycled, as
+// with SetIDEventHook. took includes any time spent waiting under
+// SetMaxGoroutines, and otherwise measures the identifier pool itself, so a
+// rising took points at contention on the pool. Like SetIDEventHook, the hook
+// is global and called on the allocating goroutine, so it should be cheap,
+// such as recording into a histogram. Without a hook, Acquire does not read
+// the clock. Passing nil removes the hook.
+func SetAcquireHook(fn func(took time.Duration, reused bool)) {
+	stackTagPool.setTimedHook(fn)
+}
+
+// SetGoroutineIDFunc replaces the goroutine identifier, which every
+// ContextManager stores values under, with whatever fn returns, so that
+// values follow some logical unit of work, such as a worker in a runtime that
+// moves workers between goroutines, rather than the goroutine itself. When fn
+// reports false the package falls back to its own identifiers. Passing nil
+// removes fn.
+//
+// This is a process-wide setting and there is no protection against misuse,
+// so fn must honor a strict contract:
+//
+//   - Each identifier must belong to one logical worker at a time, and stay
+//     the same for as long as that worker uses gls. Two workers sharing an
+//     identifier see, and overwrite, each other's values.
+//   - Only one goroutine may act for an identifier at any moment; values are
+//     not locked against concurrent use of the same identifier.
+//   - Identifiers must be small. Every ContextManager sizes its storage to
+//     the largest identifier it has seen.
+//   - The package's own identifiers count up from zero, so fn must cover every
+//     goroutine that uses gls while it is installed, or the two will collide.
+//   - fn is called on every GetValue and SetValues, so it must be cheap, and it
+//     must not use gls itself.
+//
+// fn should be installed before any values are set, and not changed while any
+// are.
+func SetGoroutineIDFunc(fn func() (gid uint32, ok bool)) {
+	idFunc.Store(fn)
+}
+
+func customGoroutineId() (gid uint32, ok bool) {
+	if fn, _ := idFunc.Load().(func() (uint32, bool)); fn != nil {
+		return fn()
+	}
+	return 0, false
+}
+
+// SetMaxGoroutines caps the number of goroutines that can hold an identifier,
+// and so have values on a ContextManager, at once, bounding the memory every
+// ContextManager can grow to. Once n goroutines hold one, a goroutine needing
+// a new identifier either fails, making SetValuesE return ErrIDExhausted (and
+// SetValues panic with it), or, if block is true, waits until another
+// goroutine's outermost scope exits. A non-positive n removes the cap.
+//
+// Blocking turns a goroutine explosion into backpressure, but can deadlock if
+// the goroutines holding identifiers are themselves waiting on goroutines
+// that need one. Identifiers handed out before the cap was set stay in
+// circulation, so it belongs at the start of the program.
+func SetMaxGoroutines(n int, block bool) {
+	var maxID uint32
+	switch {
+	case n <= 0:
+	case uint64(n) >= math.MaxUint32:
+		maxID = math.MaxUint32
+	default:
+		maxID = uint32(n)
+	}
+	stackTagPool.setMax(maxID, block)
 }
@@ -0,0 +1,43 @@
+// Package glsexpvar publishes gls's activity counters through expvar, so that
+// they show up on the standard /debug/vars endpoint. It is separate from gls
+// because importing expvar registers that endpoint on http.DefaultServeMux,
+// which no program should get merely by importing gls.
+package glsexpvar
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/HyungrakJo/gls"
+)
+
+var publishOnce sync.Once
+
+// Publish publishes gls.ReadStats under the expvar name "gls", as a map with
+// the following entries:
+//
+//	managers          ContextManagers registered since startup
+//	go_calls          goroutines started by gls.Go and the functions built
+//	                  on it, such as gls.GoSeeded and gls.GoSlice
+//	set_values        SetValues calls that set at least one value
+//	max_goroutine_id  number of goroutine identifiers handed out so far,
+//	                  the high-water mark of concurrently tagged goroutines
+//	extends           times a ContextManager grew its storage
+//
+// The counters are read whenever /debug/vars is served, and cover everything
+// since startup, including activity before Publish was called. Calling
+// Publish more than once has no further effect.
+func Publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("gls", expvar.Func(func() interface{} {
+			stats := gls.ReadStats()
+			return map[string]uint64{
+				"managers":         stats.Managers,
+				"go_calls":         stats.GoCalls,
+				"set_values":       stats.SetValuesCalls,
+				"max_goroutine_id": stats.MaxGoroutineID,
+				"extends":          stats.Extends,
+			}
+		}))
+	})
+}
@@ -0,0 +1,49 @@
+package glsexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/HyungrakJo/gls"
+)
+
+func TestPublish(t *testing.T) {
+	Publish()
+	Publish()
+
+	read := func() map[string]uint64 {
+		v := expvar.Get("gls")
+		if v == nil {
+			t.Fatalf("expected gls to be published")
+		}
+		var stats map[string]uint64
+		if err := json.Unmarshal([]byte(v.String()), &stats); err != nil {
+			t.Fatalf("expected a JSON object, got %v", err)
+		}
+		return stats
+	}
+
+	before := read()
+	mgr := gls.NewContextManager(gls.Option{})
+	defer mgr.Unregister()
+	mgr.SetValues(gls.Values{"key": "val"}, func() {
+		done := make(chan struct{})
+		gls.Go(func() { close(done) })
+		<-done
+	})
+	after := read()
+
+	for _, name := range []string{"managers", "go_calls", "set_values"} {
+		if after[name] <= before[name] {
+			t.Fatalf("expected %s to grow, got %d then %d",
+				name, before[name], after[name])
+		}
+	}
+	if after["max_goroutine_id"] == 0 {
+		t.Fatalf("expected identifiers to have been handed out")
+	}
+	if _, ok := after["extends"]; !ok {
+		t.Fatalf("expected extends to be published, got %v", after)
+	}
+}
@@ -0,0 +1,77 @@
+// Package glsotel exports gls values as OpenTelemetry attributes. It lives in
+// its own module so that gls itself does not depend on OpenTelemetry.
+package glsotel
+
+import (
+	"fmt"
+
+	"github.com/HyungrakJo/gls"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Option controls how Attributes converts values.
+type Option struct {
+	// StringifyUnsupported makes values of types without a matching
+	// attribute type be exported as strings, formatted with fmt.Sprint.
+	// By default they are skipped.
+	StringifyUnsupported bool
+}
+
+// Attributes is Option{}.Attributes.
+func Attributes(m *gls.ContextManager, keys ...interface{}) []attribute.KeyValue {
+	return Option{}.Attributes(m, keys...)
+}
+
+// Attributes reads keys from m for the current goroutine and converts their
+// values to attributes, so that
+//
+//	span.SetAttributes(glsotel.Attributes(mgr, "user", "tenant")...)
+//
+// enriches a span in one line. The attribute type follows the value's type:
+// string, bool, int and int64, and float64 map to their attribute
+// counterparts. Attributes are named after their key: a string key is used
+// as is, a fmt.Stringer key by its String method, and any other key by
+// fmt.Sprint. Keys that aren't set are skipped.
+func (o Option) Attributes(m *gls.ContextManager, keys ...interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		value, ok := m.GetValue(key)
+		if !ok {
+			continue
+		}
+		if attr, ok := o.convert(attribute.Key(keyName(key)), value); ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+func (o Option) convert(key attribute.Key, value interface{}) (
+	attr attribute.KeyValue, ok bool) {
+	switch v := value.(type) {
+	case string:
+		return key.String(v), true
+	case bool:
+		return key.Bool(v), true
+	case int:
+		return key.Int(v), true
+	case int64:
+		return key.Int64(v), true
+	case float64:
+		return key.Float64(v), true
+	}
+	if o.StringifyUnsupported {
+		return key.String(fmt.Sprint(value)), true
+	}
+	return attr, false
+}
+
+func keyName(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case fmt.Stringer:
+		return k.String()
+	}
+	return fmt.Sprint(key)
+}
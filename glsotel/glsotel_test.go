@@ -0,0 +1,60 @@
+package glsotel
+
+import (
+	"testing"
+
+	"github.com/HyungrakJo/gls"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type point struct{ x, y int }
+
+type named string
+
+func (n named) String() string { return "named." + string(n) }
+
+func TestAttributes(t *testing.T) {
+	mgr := gls.NewContextManager(gls.Option{})
+	defer mgr.Unregister()
+
+	values := gls.Values{
+		"string":      "val",
+		"bool":        true,
+		"int":         1,
+		"int64":       int64(2),
+		"float64":     3.5,
+		"unsupported": point{1, 2},
+		named("key"):  "stringer",
+	}
+	keys := []interface{}{"string", "bool", "int", "int64", "float64",
+		"unsupported", named("key"), "missing"}
+
+	check := func(got, expected []attribute.KeyValue) {
+		if len(got) != len(expected) {
+			t.Fatalf("expected %d attributes, got %v", len(expected), got)
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Fatalf("expected attribute %v, got %v", expected[i], got[i])
+			}
+		}
+	}
+
+	supported := func(extra ...attribute.KeyValue) []attribute.KeyValue {
+		return append([]attribute.KeyValue{
+			attribute.String("string", "val"),
+			attribute.Bool("bool", true),
+			attribute.Int("int", 1),
+			attribute.Int64("int64", 2),
+			attribute.Float64("float64", 3.5),
+		}, extra...)
+	}
+	mgr.SetValues(values, func() {
+		check(Attributes(mgr, keys...),
+			supported(attribute.String("named.key", "stringer")))
+		check(Option{StringifyUnsupported: true}.Attributes(mgr, keys...),
+			supported(attribute.String("unsupported", "{1 2}"),
+				attribute.String("named.key", "stringer")))
+	})
+	check(Attributes(mgr, keys...), nil)
+}
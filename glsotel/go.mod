@@ -0,0 +1,14 @@
+module github.com/HyungrakJo/gls/glsotel
+
+go 1.25.0
+
+require (
+	github.com/HyungrakJo/gls v0.0.0-20261015075414-3de78e1f26e4
+	go.opentelemetry.io/otel v1.46.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/gopherjs/gopherjs v1.17.2 // indirect
+	golang.design/x/lockfree v0.0.1 // indirect
+)
@@ -0,0 +1,58 @@
+// Package glspool adapts worker pools to carry gls values into the tasks they
+// run. Pools start their own goroutines, which begin without the values of
+// the goroutine submitting the task; the adapters here wrap each task with
+// gls.Wrap as it is submitted. They match pools by method signature, so gls
+// doesn't depend on any pool library:
+//
+//	pool, _ := ants.NewPool(10)
+//	submitter := glspool.WrapSubmitter(pool)
+//	submitter.Submit(task) // task sees the submitter's values
+//
+// For pools with other APIs, wrap tasks with gls.Wrap, or gls.Capture, by
+// hand.
+package glspool
+
+import (
+	"github.com/HyungrakJo/gls"
+)
+
+// Submitter is a pool that runs tasks submitted with Submit, such as
+// *ants.Pool from github.com/panjf2000/ants.
+type Submitter interface {
+	Submit(task func()) error
+}
+
+// Goer is a pool that runs tasks passed to Go, such as *pool.Pool or
+// conc.WaitGroup from github.com/sourcegraph/conc.
+type Goer interface {
+	Go(task func())
+}
+
+// WrapSubmitter returns a Submitter that submits every task to pool wrapped
+// with gls.Wrap, so the task runs with the values the submitting goroutine
+// had.
+func WrapSubmitter(pool Submitter) Submitter {
+	return submitter{pool: pool}
+}
+
+type submitter struct {
+	pool Submitter
+}
+
+func (s submitter) Submit(task func()) error {
+	return s.pool.Submit(gls.Wrap(task))
+}
+
+// WrapGoer returns a Goer that passes every task to pool wrapped with
+// gls.Wrap, so the task runs with the values the calling goroutine had.
+func WrapGoer(pool Goer) Goer {
+	return goer{pool: pool}
+}
+
+type goer struct {
+	pool Goer
+}
+
+func (g goer) Go(task func()) {
+	g.pool.Go(gls.Wrap(task))
+}
@@ -0,0 +1,88 @@
+package glspool
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/HyungrakJo/gls"
+)
+
+// pool is a minimal stand-in for a worker pool: a fixed set of goroutines
+// started with the go keyword, running tasks from a channel.
+type pool struct {
+	tasks chan func()
+	wg    sync.WaitGroup
+}
+
+func newPool(workers int) *pool {
+	p := &pool{tasks: make(chan func())}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				task()
+				p.wg.Done()
+			}
+		}()
+	}
+	return p
+}
+
+func (p *pool) Submit(task func()) error {
+	p.wg.Add(1)
+	p.tasks <- task
+	return nil
+}
+
+func (p *pool) Go(task func()) {
+	p.Submit(task)
+}
+
+func (p *pool) close() {
+	p.wg.Wait()
+	close(p.tasks)
+}
+
+func TestWrapSubmitter(t *testing.T) {
+	testAdapter(t, func(p *pool) func(task func()) {
+		s := WrapSubmitter(p)
+		return func(task func()) {
+			if err := s.Submit(task); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	})
+}
+
+func TestWrapGoer(t *testing.T) {
+	testAdapter(t, func(p *pool) func(task func()) {
+		return WrapGoer(p).Go
+	})
+}
+
+func testAdapter(t *testing.T, adapt func(p *pool) func(task func())) {
+	mgr := gls.NewContextManager(gls.Option{})
+	defer mgr.Unregister()
+
+	p := newPool(2)
+	submit := adapt(p)
+	var mtx sync.Mutex
+	seen := make(map[interface{}]bool)
+	for _, request := range []string{"a", "b", "c"} {
+		mgr.SetValues(gls.Values{"request": request}, func() {
+			submit(func() {
+				val, _ := mgr.GetValue("request")
+				mtx.Lock()
+				seen[val] = true
+				mtx.Unlock()
+			})
+		})
+	}
+	p.close()
+
+	for _, request := range []string{"a", "b", "c"} {
+		if !seen[request] {
+			t.Fatalf("expected task for request %s to see it, got %v",
+				request, seen)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Package glstest provides helpers for testing that gls values are, or are
+// not, propagated to where they are expected. It is kept separate from gls so
+// it is not pulled into production builds.
+package glstest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HyungrakJo/gls"
+)
+
+// AssertPropagated fails the test unless key is set on m for the current
+// goroutine to a value deeply equal to want. It is typically called at the
+// start of a goroutine started with gls.Go. Failures are reported with
+// t.Errorf rather than t.Fatalf, so it is safe to call from goroutines other
+// than the one running the test.
+func AssertPropagated(t testing.TB, m *gls.ContextManager, key, want interface{}) {
+	t.Helper()
+	got, ok := m.GetValue(key)
+	if !ok {
+		t.Errorf("gls value for key %v was not propagated: want %v, got no value",
+			key, want)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("gls value for key %v was propagated incorrectly: want %v, got %v",
+			key, want, got)
+	}
+}
+
+// AssertNotPropagated fails the test if key is set on m for the current
+// goroutine. Like AssertPropagated, it reports failures with t.Errorf.
+func AssertNotPropagated(t testing.TB, m *gls.ContextManager, key interface{}) {
+	t.Helper()
+	if got, ok := m.GetValue(key); ok {
+		t.Errorf("gls value for key %v was propagated unexpectedly: got %v",
+			key, got)
+	}
+}
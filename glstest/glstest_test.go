@@ -0,0 +1,54 @@
+package glstest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/HyungrakJo/gls"
+)
+
+type recorder struct {
+	testing.TB
+	mtx    sync.Mutex
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	mgr := gls.NewContextManager(gls.Option{})
+	defer mgr.Unregister()
+
+	rec := &recorder{TB: t}
+	mgr.SetValues(gls.Values{"key": []string{"val"}}, func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		gls.Go(func() {
+			defer wg.Done()
+			AssertPropagated(rec, mgr, "key", []string{"val"})
+			AssertNotPropagated(rec, mgr, "other")
+		})
+		go func() {
+			defer wg.Done()
+			AssertNotPropagated(rec, mgr, "key")
+		}()
+		wg.Wait()
+	})
+	if len(rec.errors) != 0 {
+		t.Fatalf("expected no failures, got %v", rec.errors)
+	}
+
+	mgr.SetValues(gls.Values{"key": "val"}, func() {
+		AssertPropagated(rec, mgr, "key", "other")
+		AssertPropagated(rec, mgr, "missing", "val")
+		AssertNotPropagated(rec, mgr, "key")
+	})
+	if len(rec.errors) != 3 {
+		t.Fatalf("expected 3 failures, got %v", rec.errors)
+	}
+}
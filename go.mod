@@ -1,9 +1,10 @@
 module github.com/HyungrakJo/gls
 
-go 1.16
+go 1.18
 
 require (
-	github.com/changkun/lockfree v0.0.1 // indirect
 	github.com/gopherjs/gopherjs v1.17.2
 	golang.design/x/lockfree v0.0.1
 )
+
+require github.com/changkun/lockfree v0.0.1 // indirect
@@ -0,0 +1,58 @@
+package gls
+
+import (
+	"sync"
+)
+
+// GoSlice calls worker once per element of items, each in its own goroutine
+// started like Go would, and waits for all of them to return. The current
+// context is captured once, up front, and every worker sees it.
+func GoSlice[T any](items []T, worker func(item T)) {
+	GoSliceLimited(items, len(items), worker)
+}
+
+// GoSliceLimited is like GoSlice, but runs at most limit workers at a time. A
+// limit less than 1 is treated as 1.
+func GoSliceLimited[T any](items []T, limit int, worker func(item T)) {
+	if limit < 1 {
+		limit = 1
+	}
+	apply := captureForGo()
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	wg.Add(len(items))
+	for _, item := range items {
+		sem <- struct{}{}
+		cb := func(item T) func() {
+			return func() {
+				defer func() { <-sem }()
+				defer wg.Done()
+				worker(item)
+			}
+		}(item)
+		go apply(withGoHooks(cb))
+	}
+	wg.Wait()
+}
+
+// GoSliceErr is like GoSlice for workers that can fail. It returns the errors
+// returned by workers, in the order of the items that caused them, or nil if
+// every worker succeeded.
+func GoSliceErr[T any](items []T, worker func(item T) error) []error {
+	errs := make([]error, len(items))
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	GoSlice(indexes, func(i int) {
+		errs[i] = worker(items[i])
+	})
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,72 @@
+package gls
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGoSlice(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	items := []int{1, 2, 3, 4, 5}
+	var mtx sync.Mutex
+	seen := make(map[int]bool)
+	mgr.SetValues(Values{"key": "val"}, func() {
+		GoSlice(items, func(item int) {
+			if val, _ := mgr.GetValue("key"); val != "val" {
+				t.Errorf("expected worker to see parent value, got %v", val)
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			seen[item] = true
+		})
+	})
+	if len(seen) != len(items) {
+		t.Fatalf("expected all %d items to be processed, got %d", len(items),
+			len(seen))
+	}
+}
+
+func TestGoSliceLimited(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var running, peak int32
+	mgr.SetValues(Values{"key": "val"}, func() {
+		GoSliceLimited(make([]int, 20), 3, func(int) {
+			if val, _ := mgr.GetValue("key"); val != "val" {
+				t.Errorf("expected worker to see parent value, got %v", val)
+			}
+			cur := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+					break
+				}
+			}
+		})
+	})
+	if peak > 3 {
+		t.Fatalf("expected at most 3 concurrent workers, got %d", peak)
+	}
+}
+
+func TestGoSliceErr(t *testing.T) {
+	errOdd := errors.New("odd")
+	errs := GoSliceErr([]int{1, 2, 3, 4}, func(item int) error {
+		if item%2 == 1 {
+			return errOdd
+		}
+		return nil
+	})
+	if len(errs) != 2 || errs[0] != errOdd || errs[1] != errOdd {
+		t.Fatalf("expected two errors, got %v", errs)
+	}
+	if errs := GoSliceErr([]int{2, 4}, func(int) error { return nil }); errs != nil {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+}
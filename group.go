@@ -0,0 +1,70 @@
+package gls
+
+import (
+	"sync"
+)
+
+// Group tracks the goroutines started with its Go method, so that they can be
+// waited for and told to stop together, such as when tearing down a request.
+type Group struct {
+	mgr        *ContextManager
+	wg         sync.WaitGroup
+	done       chan struct{}
+	cancelOnce sync.Once
+}
+
+type groupDoneKey struct{}
+
+// BeginGroup returns a new Group whose goroutines find its cancellation
+// channel through m.GroupDone.
+func (m *ContextManager) BeginGroup() *Group {
+	return &Group{mgr: m, done: make(chan struct{})}
+}
+
+// Go starts cb in a new goroutine exactly as the package-level Go does, and
+// tracks it until cb returns. Within cb, the ContextManager the Group was
+// begun on returns the Group's cancellation channel from GroupDone.
+// Goroutines that cb itself starts with Go inherit the channel, but are not
+// tracked; start them with the Group's Go to have Wait wait for them too.
+func (g *Group) Go(cb func()) {
+	g.wg.Add(1)
+	Go(func() {
+		defer g.wg.Done()
+		g.mgr.SetValues(Values{groupDoneKey{}: g.done}, cb)
+	})
+}
+
+// Wait blocks until every goroutine started with Go has returned.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
+
+// Cancel closes the channel returned by Done, signalling the Group's
+// goroutines to stop. It does not wait for them to do so; follow it with
+// Wait for that. Cancel may be called more than once, and from any goroutine.
+func (g *Group) Cancel() {
+	g.cancelOnce.Do(func() { close(g.done) })
+}
+
+// Done returns a channel that is closed when Cancel is called.
+func (g *Group) Done() <-chan struct{} {
+	return g.done
+}
+
+// GroupDone returns the cancellation channel of the Group the current
+// goroutine was started by, or nil if there is none. Receiving from a nil
+// channel blocks forever, so goroutines can select on the result whether or
+// not they run within a Group:
+//
+//	select {
+//	case <-mgr.GroupDone():
+//		return
+//	case job := <-jobs:
+//		...
+//	}
+func (m *ContextManager) GroupDone() <-chan struct{} {
+	if done, ok := m.GetValue(groupDoneKey{}); ok {
+		return done.(chan struct{})
+	}
+	return nil
+}
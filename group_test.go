@@ -0,0 +1,71 @@
+package gls
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupWait(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if mgr.GroupDone() != nil {
+		t.Fatalf("expected no group channel outside of a group")
+	}
+
+	var finished int32
+	mgr.SetValues(Values{"key": "val"}, func() {
+		group := mgr.BeginGroup()
+		for i := 0; i < 10; i++ {
+			group.Go(func() {
+				time.Sleep(time.Millisecond)
+				if val, _ := mgr.GetValue("key"); val != "val" {
+					t.Errorf("expected propagated value val, got %v", val)
+				}
+				if mgr.GroupDone() != group.Done() {
+					t.Errorf("expected the group's channel in its goroutine")
+				}
+				atomic.AddInt32(&finished, 1)
+			})
+		}
+		group.Wait()
+	})
+	if n := atomic.LoadInt32(&finished); n != 10 {
+		t.Fatalf("expected Wait to wait for 10 goroutines, got %d", n)
+	}
+}
+
+func TestGroupCancel(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	group := mgr.BeginGroup()
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	group.Go(func() {
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			close(started)
+			<-mgr.GroupDone()
+		})
+		<-done
+		close(stopped)
+	})
+
+	<-started
+	select {
+	case <-stopped:
+		t.Fatalf("expected goroutine to run until cancelled")
+	default:
+	}
+	group.Cancel()
+	group.Cancel()
+	group.Wait()
+	select {
+	case <-stopped:
+	default:
+		t.Fatalf("expected cancellation to reach grandchildren started with Go")
+	}
+}
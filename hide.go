@@ -0,0 +1,66 @@
+package gls
+
+// HideKeys calls fn with keys removed from the current goroutine's values on
+// this ContextManager, and puts them back when fn returns or panics. Within
+// fn, GetValue reports hidden keys as not set, as if no scope had set them,
+// so their defaults, if any, apply. This is meant for security boundaries,
+// such as keeping an admin token from a sub-operation. SetValues scopes
+// within fn may set hidden keys again as usual.
+//
+// Hidden keys are left out of everything that copies the goroutine's values,
+// such as Snapshot, so goroutines started by Go within fn don't inherit them
+// either, and keep not seeing them after fn returns. Like SetValues, HideKeys
+// panics within Freeze and respects Pin: a pinned key stays visible under
+// PinIgnore, and HideKeys panics under PinPanic. Keys that aren't set are
+// ignored.
+func (m *ContextManager) HideKeys(keys []interface{}, fn func()) {
+	gid, ok := GetGoroutineId()
+	if !ok || len(keys) == 0 {
+		fn()
+		return
+	}
+	hide := make(Values, len(keys))
+	for _, key := range keys {
+		hide[m.normalizeKey(key)] = nil
+	}
+	if flags := goroutineFlags.state(gid); flags != nil {
+		if _, frozen := flags[frozenKey{mgr: m}]; frozen {
+			panic("gls: HideKeys called within Freeze")
+		}
+		hide = m.unpinned(flags, hide)
+	}
+
+	var (
+		state  Values
+		hidden Values
+	)
+	m.extendLock.RLock()
+	if gid < uint32(len(m.values)) {
+		state = m.values[gid]
+	}
+	for key := range hide {
+		if val, ok := state[key]; ok {
+			if hidden == nil {
+				hidden = make(Values, len(hide))
+			}
+			hidden[key] = val
+			delete(state, key)
+		}
+	}
+	if hidden != nil {
+		m.bumpGeneration(gid)
+	}
+	m.extendLock.RUnlock()
+
+	if hidden != nil {
+		defer func() {
+			m.extendLock.RLock()
+			defer m.extendLock.RUnlock()
+			for key, val := range hidden {
+				state[key] = val
+			}
+			m.bumpGeneration(gid)
+		}()
+	}
+	fn()
+}
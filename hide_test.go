@@ -0,0 +1,74 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestHideKeys(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"token": "admin", "user": "bob"}, func() {
+		mgr.HideKeys([]interface{}{"token", "missing"}, func() {
+			if val, ok := mgr.GetValue("token"); ok {
+				t.Fatalf("expected token to be hidden, got %v", val)
+			}
+			if val, _ := mgr.GetValue("user"); val != "bob" {
+				t.Fatalf("expected user to stay visible, got %v", val)
+			}
+			mgr.SetValues(Values{"token": "guest"}, func() {
+				if val, _ := mgr.GetValue("token"); val != "guest" {
+					t.Fatalf("expected an inner scope to set token, got %v", val)
+				}
+			})
+			if val, ok := mgr.GetValue("token"); ok {
+				t.Fatalf("expected token to be hidden again, got %v", val)
+			}
+		})
+		if val, _ := mgr.GetValue("token"); val != "admin" {
+			t.Fatalf("expected token to be restored, got %v", val)
+		}
+
+		func() {
+			defer func() { recover() }()
+			mgr.HideKeys([]interface{}{"token"}, func() { panic("boom") })
+		}()
+		if val, _ := mgr.GetValue("token"); val != "admin" {
+			t.Fatalf("expected token to be restored after a panic, got %v", val)
+		}
+	})
+}
+
+func TestHideKeysPropagation(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"token": "admin", "user": "bob"}, func() {
+		done := make(chan Values)
+		release := make(chan struct{})
+		mgr.HideKeys([]interface{}{"token"}, func() {
+			Go(func() {
+				<-release
+				done <- mgr.Snapshot()
+			})
+		})
+		close(release)
+		values := <-done
+		if _, ok := values["token"]; ok || values["user"] != "bob" {
+			t.Fatalf("expected only user in the child, got %v", values)
+		}
+	})
+}
+
+func TestHideKeysPinned(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.Pin("tenant", "acme", func() {
+		mgr.HideKeys([]interface{}{"tenant"}, func() {
+			if val, _ := mgr.GetValue("tenant"); val != "acme" {
+				t.Fatalf("expected a pinned key to stay visible, got %v", val)
+			}
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package gls
+
+import (
+	"sync/atomic"
+)
+
+var (
+	goPrologue atomic.Value // func()
+	goEpilogue atomic.Value // func()
+)
+
+// SetGoPrologue registers fn to be run at the start of every goroutine
+// started with Go, after ContextManager values have been re-established (so
+// fn can read them) and before the goroutine's callback. This is the place to
+// start a span or set pprof labels for every goroutine. The prologue is
+// process-global and runs on every Go call, so it should be cheap. Passing nil
+// removes it.
+func SetGoPrologue(fn func()) {
+	goPrologue.Store(fn)
+}
+
+// SetGoEpilogue registers fn to be run at the end of every goroutine started
+// with Go, after the goroutine's callback returns or panics, while
+// ContextManager values are still set. In the panic case fn runs while the
+// panic unwinds and the panic then continues as usual. Like the prologue, the
+// epilogue is process-global and should be cheap. Passing nil removes it.
+func SetGoEpilogue(fn func()) {
+	goEpilogue.Store(fn)
+}
+
+// withGoHooks returns cb wrapped with the current prologue and epilogue, or cb
+// itself if there are none. Every goroutine the package starts passes through
+// it, so it also counts them for ReadStats.
+func withGoHooks(cb func()) func() {
+	atomic.AddUint64(&goCalls, 1)
+	prologue, _ := goPrologue.Load().(func())
+	epilogue, _ := goEpilogue.Load().(func())
+	if prologue == nil && epilogue == nil {
+		return cb
+	}
+	return func() {
+		if epilogue != nil {
+			defer epilogue()
+		}
+		if prologue != nil {
+			prologue()
+		}
+		cb()
+	}
+}
@@ -0,0 +1,43 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestGoPrologueEpilogue(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	events := make(chan string, 3)
+	record := func(event string) func() {
+		return func() {
+			val, _ := mgr.GetValue("key")
+			events <- event + ":" + val.(string)
+		}
+	}
+	SetGoPrologue(record("prologue"))
+	SetGoEpilogue(record("epilogue"))
+	defer SetGoPrologue(nil)
+	defer SetGoEpilogue(nil)
+
+	mgr.SetValues(Values{"key": "val"}, func() {
+		Go(record("cb"))
+	})
+
+	for _, exp := range []string{"prologue:val", "cb:val", "epilogue:val"} {
+		if event := <-events; event != exp {
+			t.Fatalf("expected event %s, got %s", exp, event)
+		}
+	}
+}
+
+func TestGoEpilogueOnPanic(t *testing.T) {
+	recovered := make(chan interface{})
+	SetGoEpilogue(func() { recovered <- recover() })
+	defer SetGoEpilogue(nil)
+
+	Go(func() { panic("boom") })
+	if r := <-recovered; r != "boom" {
+		t.Fatalf("expected epilogue to run while panicking, got %v", r)
+	}
+}
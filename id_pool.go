@@ -5,29 +5,140 @@ package gls
 // per-process possible
 
 import (
+	"math"
+	"sync"
 	"sync/atomic"
-
-	"golang.design/x/lockfree"
+	"time"
 )
 
 type idPool struct {
-	queue *lockfree.Queue
+	free  freeList
 	curID uint32
+	maxID uint32       // exclusive; zero means math.MaxUint32
+	hook  atomic.Value // func(id uint32, reused bool)
+	timed atomic.Value // func(took time.Duration, reused bool)
+
+	// block makes Acquire wait for a Release instead of failing once maxID
+	// is reached. waiters counts the Acquire calls doing so, so that
+	// Release only takes waitMtx when someone is waiting.
+	block   int32
+	waiters int32
+	waitMtx sync.Mutex
+	waitC   *sync.Cond
+}
+
+func newIDPool() *idPool {
+	p := &idPool{free: newFreeList()}
+	p.waitC = sync.NewCond(&p.waitMtx)
+	return p
+}
+
+// newID hands out the next never-used id, rather than wrapping around and
+// handing out an id that may still be in use once they have all been taken.
+func (p *idPool) newID() (uint32, error) {
+	maxID := atomic.LoadUint32(&p.maxID)
+	if maxID == 0 {
+		maxID = math.MaxUint32
+	}
+	for {
+		curID := atomic.LoadUint32(&p.curID)
+		if curID >= maxID {
+			return 0, ErrIDExhausted
+		}
+		if atomic.CompareAndSwapUint32(&p.curID, curID, curID+1) {
+			return curID, nil
+		}
+	}
+}
+
+func (p *idPool) Acquire() (id uint32, err error) {
+	var (
+		reused bool
+		start  time.Time
+	)
+	timed, _ := p.timed.Load().(func(time.Duration, bool))
+	if timed != nil {
+		start = time.Now()
+	}
+	if id, reused = p.free.pop(); !reused {
+		if id, err = p.newID(); err != nil {
+			if atomic.LoadInt32(&p.block) == 0 {
+				return 0, err
+			}
+			if id, reused, err = p.wait(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if timed != nil {
+		timed(time.Since(start), reused)
+	}
+	if hook, _ := p.hook.Load().(func(uint32, bool)); hook != nil {
+		hook(id, reused)
+	}
+	return id, nil
 }
 
-func (p *idPool) newID() uint32 {
-	curID := atomic.AddUint32(&p.curID, 1)
-	return curID - 1
+// wait blocks until an id is available and takes it, or until blocking is
+// turned off.
+func (p *idPool) wait() (id uint32, reused bool, err error) {
+	atomic.AddInt32(&p.waiters, 1)
+	defer atomic.AddInt32(&p.waiters, -1)
+	p.waitMtx.Lock()
+	defer p.waitMtx.Unlock()
+	for {
+		if id, reused = p.free.pop(); reused {
+			return id, true, nil
+		}
+		if id, err = p.newID(); err == nil {
+			return id, false, nil
+		}
+		if atomic.LoadInt32(&p.block) == 0 {
+			return 0, false, err
+		}
+		p.waitC.Wait()
+	}
 }
 
-func (p *idPool) Acquire() (id uint32) {
-	if item := p.queue.Dequeue(); item != nil {
-		return item.(uint32)
-	} else {
-		return p.newID()
+// setMax limits the pool to ids below maxID, with zero meaning no limit.
+// Ids already handed out are unaffected, and keep being reused once
+// released.
+func (p *idPool) setMax(maxID uint32, block bool) {
+	var flag int32
+	if block {
+		flag = 1
 	}
+	atomic.StoreInt32(&p.block, flag)
+	atomic.StoreUint32(&p.maxID, maxID)
+	p.waitMtx.Lock()
+	p.waitC.Broadcast()
+	p.waitMtx.Unlock()
+}
+
+func (p *idPool) setHook(fn func(id uint32, reused bool)) {
+	p.hook.Store(fn)
+}
+
+func (p *idPool) setTimedHook(fn func(took time.Duration, reused bool)) {
+	p.timed.Store(fn)
 }
 
 func (p *idPool) Release(id uint32) {
-	p.queue.Enqueue(id)
+	p.free.push(id)
+	p.wake()
+}
+
+// ReleaseMany is Release for several ids, waking waiting Acquire calls once.
+func (p *idPool) ReleaseMany(ids []uint32) {
+	p.free.pushAll(ids)
+	p.wake()
+}
+
+// wake wakes any Acquire calls waiting for an id to be released.
+func (p *idPool) wake() {
+	if atomic.LoadInt32(&p.waiters) > 0 {
+		p.waitMtx.Lock()
+		p.waitC.Broadcast()
+		p.waitMtx.Unlock()
+	}
 }
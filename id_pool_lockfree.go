@@ -0,0 +1,35 @@
+//go:build !gls_mutexpool
+// +build !gls_mutexpool
+
+package gls
+
+// This file is used by default, and keeps released ids in a lock-free queue
+
+import (
+	"golang.design/x/lockfree"
+)
+
+type freeList struct {
+	queue *lockfree.Queue
+}
+
+func newFreeList() freeList {
+	return freeList{queue: lockfree.NewQueue()}
+}
+
+func (l *freeList) pop() (id uint32, ok bool) {
+	if item := l.queue.Dequeue(); item != nil {
+		return item.(uint32), true
+	}
+	return 0, false
+}
+
+func (l *freeList) push(id uint32) {
+	l.queue.Enqueue(id)
+}
+
+func (l *freeList) pushAll(ids []uint32) {
+	for _, id := range ids {
+		l.queue.Enqueue(id)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build gls_mutexpool
+// +build gls_mutexpool
+
+package gls
+
+// This file is used for builds with the gls_mutexpool tag, which keep released
+// ids in a mutex-protected stack instead of a lock-free queue. That gives up
+// some throughput under heavy contention, but means the package builds
+// without any third-party dependencies.
+
+import (
+	"sync"
+)
+
+type freeList struct {
+	mtx *sync.Mutex
+	ids *[]uint32
+}
+
+func newFreeList() freeList {
+	return freeList{mtx: new(sync.Mutex), ids: new([]uint32)}
+}
+
+func (l *freeList) pop() (id uint32, ok bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	ids := *l.ids
+	if len(ids) == 0 {
+		return 0, false
+	}
+	id = ids[len(ids)-1]
+	*l.ids = ids[:len(ids)-1]
+	return id, true
+}
+
+func (l *freeList) push(id uint32) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	*l.ids = append(*l.ids, id)
+}
+
+func (l *freeList) pushAll(ids []uint32) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	*l.ids = append(*l.ids, ids...)
+}
@@ -0,0 +1,242 @@
+package gls
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIDPoolHook(t *testing.T) {
+	type event struct {
+		id     uint32
+		reused bool
+	}
+	var events []event
+
+	pool := newIDPool()
+	pool.setHook(func(id uint32, reused bool) {
+		events = append(events, event{id: id, reused: reused})
+	})
+
+	first, _ := pool.Acquire()
+	second, _ := pool.Acquire()
+	pool.Release(first)
+	third, _ := pool.Acquire()
+
+	expected := []event{{first, false}, {second, false}, {first, true}}
+	if third != first {
+		t.Fatalf("expected id %d to be reused, got %d", first, third)
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected event %v, got %v", expected[i], events[i])
+		}
+	}
+
+	pool.setHook(nil)
+	pool.Release(third)
+	pool.Acquire()
+	if len(events) != len(expected) {
+		t.Fatalf("expected no events after removing hook, got %d", len(events))
+	}
+}
+
+func TestSetIDEventHook(t *testing.T) {
+	var called bool
+	SetIDEventHook(func(id uint32, reused bool) { called = true })
+	defer SetIDEventHook(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		EnsureGoroutineId(func(gid uint32) {})
+	}()
+	<-done
+	if !called {
+		t.Fatalf("expected id event hook to be called")
+	}
+}
+
+func TestIDPoolExhaustion(t *testing.T) {
+	var hooked int
+	pool := newIDPool()
+	pool.maxID = 2
+	pool.setHook(func(id uint32, reused bool) { hooked++ })
+
+	first, err := pool.Acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := pool.Acquire(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := pool.Acquire(); err != ErrIDExhausted {
+		t.Fatalf("expected ErrIDExhausted, got %v", err)
+	}
+	if hooked != 2 {
+		t.Fatalf("expected hook to fire only for successful acquires, got %d",
+			hooked)
+	}
+
+	pool.Release(first)
+	if id, err := pool.Acquire(); err != nil || id != first {
+		t.Fatalf("expected released id %d to be reusable, got %d (%v)",
+			first, id, err)
+	}
+}
+
+func TestSetMaxGoroutines(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if !isolated(t) {
+		return
+	}
+
+	setInOther := func() <-chan error {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- mgr.SetValuesE(Values{"key": "other"}, func() {})
+		}()
+		return errs
+	}
+
+	SetMaxGoroutines(1, false)
+	mgr.SetValues(Values{"key": "val"}, func() {
+		if err := <-setInOther(); err != ErrIDExhausted {
+			t.Fatalf("expected ErrIDExhausted, got %v", err)
+		}
+	})
+
+	SetMaxGoroutines(1, true)
+	var errs <-chan error
+	mgr.SetValues(Values{"key": "val"}, func() {
+		errs = setInOther()
+		select {
+		case err := <-errs:
+			t.Fatalf("expected SetValuesE to block, got %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	})
+	if err := <-errs; err != nil {
+		t.Fatalf("expected SetValuesE to succeed once an id was released, got %v",
+			err)
+	}
+
+	SetMaxGoroutines(0, false)
+	mgr.SetValues(Values{"key": "val"}, func() {
+		if err := <-setInOther(); err != nil {
+			t.Fatalf("expected no cap, got %v", err)
+		}
+	})
+}
+
+// Run with and without -tags gls_mutexpool to compare free list
+// implementations.
+func BenchmarkIDPool(b *testing.B) {
+	pool := newIDPool()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id, err := pool.Acquire()
+			if err != nil {
+				b.Fatal(err)
+			}
+			pool.Release(id)
+		}
+	})
+}
+
+func TestIDPoolTimedHook(t *testing.T) {
+	var reused []bool
+	pool := newIDPool()
+	pool.setTimedHook(func(took time.Duration, r bool) {
+		if took < 0 {
+			t.Fatalf("expected a non-negative duration, got %v", took)
+		}
+		reused = append(reused, r)
+	})
+
+	first, _ := pool.Acquire()
+	pool.Release(first)
+	pool.Acquire()
+	if len(reused) != 2 || reused[0] || !reused[1] {
+		t.Fatalf("expected a fresh then a reused id, got %v", reused)
+	}
+}
+
+func TestSetAcquireHook(t *testing.T) {
+	var called bool
+	SetAcquireHook(func(took time.Duration, reused bool) { called = true })
+	defer SetAcquireHook(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		EnsureGoroutineId(func(gid uint32) {})
+	}()
+	<-done
+	if !called {
+		t.Fatalf("expected acquire hook to be called")
+	}
+}
+
+func BenchmarkIDPoolAcquireHook(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		hook func(time.Duration, bool)
+	}{
+		{"off", nil},
+		{"on", func(time.Duration, bool) {}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			pool := newIDPool()
+			pool.setTimedHook(bench.hook)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					id, err := pool.Acquire()
+					if err != nil {
+						b.Fatal(err)
+					}
+					pool.Release(id)
+				}
+			})
+		})
+	}
+}
+
+func TestSetGoroutineIDFunc(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	// a single logical worker, 7, that hops between goroutines
+	var onWorker bool
+	SetGoroutineIDFunc(func() (uint32, bool) { return 7, onWorker })
+	defer SetGoroutineIDFunc(nil)
+
+	onWorker = true
+	mgr.SetValues(Values{"key": "val"}, func() {
+		if gid, _ := GetGoroutineId(); gid != 7 {
+			t.Fatalf("expected custom id 7, got %d", gid)
+		}
+		done := make(chan interface{})
+		go func() {
+			val, _ := mgr.GetValue("key")
+			done <- val
+		}()
+		if val := <-done; val != "val" {
+			t.Fatalf("expected value to follow the worker, got %v", val)
+		}
+	})
+
+	onWorker = false
+	if _, ok := GetGoroutineId(); ok {
+		t.Fatalf("expected fallback to stack tags off the worker")
+	}
+	mgr.SetValues(Values{"key": "other"}, func() {
+		if val, _ := mgr.GetValue("key"); val != "other" {
+			t.Fatalf("expected value other, got %v", val)
+		}
+	})
+}
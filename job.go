@@ -0,0 +1,33 @@
+package gls
+
+// Job carries a payload together with the values every registered
+// ContextManager had on the goroutine that submitted it, for pools of
+// long-lived worker goroutines reading jobs off a channel, which Go doesn't
+// serve.
+type Job struct {
+	payload interface{}
+	apply   func(fn func())
+}
+
+// NewJob returns a Job holding payload and a copy of the current goroutine's
+// values, taken the same way Go takes them.
+func NewJob(payload interface{}) Job {
+	return Job{payload: payload, apply: capture()}
+}
+
+// Payload returns the payload j carries, without setting any values.
+func (j Job) Payload() interface{} {
+	return j.payload
+}
+
+// Run calls handler with j's payload and with the values j captured set, in
+// the same way as SetValues, so that handler runs in the submitter's context
+// on whichever worker received j. Values already set on the worker that the
+// submitter's do not override remain visible. The zero Job sets nothing.
+func (j Job) Run(handler func(payload interface{})) {
+	if j.apply == nil {
+		handler(j.payload)
+		return
+	}
+	j.apply(func() { handler(j.payload) })
+}
@@ -0,0 +1,66 @@
+package gls
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestJob(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	jobs := make(chan Job)
+	results := make(chan string)
+	var workers sync.WaitGroup
+	for w := 0; w < 3; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				job.Run(func(payload interface{}) {
+					request, _ := mgr.GetValue("request")
+					results <- fmt.Sprintf("%v:%v", payload, request)
+				})
+			}
+		}()
+	}
+
+	var submitters sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		submitters.Add(1)
+		go func(i int) {
+			defer submitters.Done()
+			mgr.SetValues(Values{"request": i}, func() {
+				jobs <- NewJob(i)
+			})
+		}(i)
+	}
+	go func() {
+		submitters.Wait()
+		close(jobs)
+		workers.Wait()
+		close(results)
+	}()
+
+	seen := make(map[string]bool)
+	for result := range results {
+		seen[result] = true
+	}
+	for i := 0; i < 10; i++ {
+		if expected := fmt.Sprintf("%d:%d", i, i); !seen[expected] {
+			t.Fatalf("expected job %d to run in its submitter's context, got %v",
+				i, seen)
+		}
+	}
+
+	var zero Job
+	zero.Run(func(payload interface{}) {
+		if payload != nil {
+			t.Fatalf("expected no payload, got %v", payload)
+		}
+		if _, ok := mgr.GetValue("request"); ok {
+			t.Fatalf("expected the zero Job to set nothing")
+		}
+	})
+}
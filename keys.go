@@ -0,0 +1,60 @@
+package gls
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SortedKeys returns the keys set on the current goroutine, ordered by less,
+// so that dumps of a goroutine's values come out the same on every run. A
+// nil less puts string keys first, in lexical order, followed by any other
+// keys, grouped by type and ordered by their fmt.Sprint representation.
+func (m *ContextManager) SortedKeys(less func(a, b interface{}) bool) []interface{} {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return nil
+	}
+	values := m.rawSnapshot(gid)
+	if len(values) == 0 {
+		return nil
+	}
+	keys := make([]interface{}, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sortKeys(keys, less)
+	return keys
+}
+
+// Len returns the number of keys set on the current goroutine, as
+// len(m.Snapshot()) would, without copying them. Defaults are not counted.
+func (m *ContextManager) Len() int {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return 0
+	}
+	return len(m.state(gid))
+}
+
+// sortKeys sorts keys by less, or by defaultKeyLess if less is nil.
+func sortKeys(keys []interface{}, less func(a, b interface{}) bool) {
+	if less == nil {
+		less = defaultKeyLess
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+}
+
+func defaultKeyLess(a, b interface{}) bool {
+	as, aIsString := a.(string)
+	bs, bIsString := b.(string)
+	switch {
+	case aIsString && bIsString:
+		return as < bs
+	case aIsString != bIsString:
+		return aIsString
+	}
+	if at, bt := fmt.Sprintf("%T", a), fmt.Sprintf("%T", b); at != bt {
+		return at < bt
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
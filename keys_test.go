@@ -0,0 +1,62 @@
+package gls
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSortedKeys(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if keys := mgr.SortedKeys(nil); keys != nil {
+		t.Fatalf("expected no keys outside of a scope, got %v", keys)
+	}
+
+	check := func(got []interface{}, expected string) {
+		if s := fmt.Sprintf("%#v", got); s != expected {
+			t.Fatalf("expected keys %s, got %s", expected, s)
+		}
+	}
+
+	mgr.SetValues(Values{"b": 1, "a": 1, "c": 1}, func() {
+		for i := 0; i < 10; i++ {
+			check(mgr.SortedKeys(nil), `[]interface {}{"a", "b", "c"}`)
+		}
+		check(mgr.SortedKeys(func(a, b interface{}) bool {
+			return a.(string) > b.(string)
+		}), `[]interface {}{"c", "b", "a"}`)
+
+		mgr.SetValues(Values{10: 1, 2: 1, "1": 1, 1.5: 1, true: 1}, func() {
+			for i := 0; i < 10; i++ {
+				check(mgr.SortedKeys(nil),
+					`[]interface {}{"1", "a", "b", "c", true, 1.5, 10, 2}`)
+			}
+		})
+	})
+}
+
+func TestLen(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if n := mgr.Len(); n != 0 {
+		t.Fatalf("expected 0 keys outside of a scope, got %d", n)
+	}
+	mgr.SetValues(Values{"a": 1, "b": 2}, func() {
+		if n := mgr.Len(); n != 2 {
+			t.Fatalf("expected 2 keys, got %d", n)
+		}
+		mgr.SetValues(Values{"b": 3, "c": 4}, func() {
+			if n := mgr.Len(); n != 3 {
+				t.Fatalf("expected 3 keys, got %d", n)
+			}
+		})
+		if n := mgr.Len(); n != 2 {
+			t.Fatalf("expected 2 keys after the inner scope, got %d", n)
+		}
+	})
+	if n := mgr.Len(); n != 0 {
+		t.Fatalf("expected 0 keys after the scope, got %d", n)
+	}
+}
@@ -0,0 +1,65 @@
+package gls
+
+import (
+	"sync"
+)
+
+type lazyValue struct {
+	mtx     sync.Mutex
+	done    bool
+	compute func() interface{}
+	value   interface{}
+}
+
+// get returns v's value, calling compute first unless an earlier call to it
+// returned.
+func (v *lazyValue) get() interface{} {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if !v.done {
+		v.value = v.compute()
+		v.done, v.compute = true, nil
+	}
+	return v.value
+}
+
+// peek returns v's value if it has been computed.
+func (v *lazyValue) peek() (value interface{}, ok bool) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.value, v.done
+}
+
+// SetLazy is like SetValues with a single key, except that the value is not
+// computed up front. The first GetValue of key within fn calls compute and
+// stores the result in place of compute, so later reads, including from
+// nested scopes, get the same result without computing it again. If key is
+// never read, compute is never called.
+//
+// compute runs at most once, even across goroutines: one started with Go
+// before key was first read inherits the uncomputed value, and shares its
+// result with the parent, whichever of them reads key first calling compute
+// while any other waits. compute therefore needs no locking of its own, but
+// must not read key itself. If compute panics, the next read calls it again.
+func (m *ContextManager) SetLazy(key interface{}, compute func() interface{},
+	fn func()) {
+	m.SetValues(Values{key: &lazyValue{compute: compute}}, fn)
+}
+
+// resolve returns the value to hand out for key, computing it first if it was
+// set by SetLazy. ok is false if the value can't be decompressed.
+func (m *ContextManager) resolve(state Values, key, value interface{}) (
+	resolved interface{}, ok bool) {
+	switch v := value.(type) {
+	case *lazyValue:
+		value = v.get()
+		m.extendLock.RLock()
+		state[key] = value
+		m.extendLock.RUnlock()
+	case *sharedValue:
+		value = v.value
+	case *compressedValue:
+		return v.bytes()
+	}
+	return value, true
+}
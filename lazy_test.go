@@ -0,0 +1,114 @@
+package gls
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetLazy(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	computed := 0
+	compute := func() interface{} {
+		computed++
+		return computed
+	}
+
+	mgr.SetLazy("unread", compute, func() {})
+	if computed != 0 {
+		t.Fatalf("expected unread lazy value not to be computed")
+	}
+
+	mgr.SetLazy("key", compute, func() {
+		if computed != 0 {
+			t.Fatalf("expected lazy value not to be computed before reading")
+		}
+		mgr.SetValues(Values{"other": "val"}, func() {
+			if val, _ := mgr.GetValue("key"); val != 1 {
+				t.Fatalf("expected computed value 1, got %v", val)
+			}
+		})
+		if val, _ := mgr.GetValue("key"); val != 1 {
+			t.Fatalf("expected cached value 1, got %v", val)
+		}
+		mgr.SetValues(Values{"key": "shadow"}, func() {
+			if val, _ := mgr.GetValue("key"); val != "shadow" {
+				t.Fatalf("expected shadowed value, got %v", val)
+			}
+		})
+		if val, _ := mgr.GetValue("key"); val != 1 {
+			t.Fatalf("expected cached value 1 after shadow, got %v", val)
+		}
+	})
+	if computed != 1 {
+		t.Fatalf("expected a single computation, got %d", computed)
+	}
+	if _, ok := mgr.GetValue("key"); ok {
+		t.Fatalf("expected lazy value to be removed after its scope")
+	}
+}
+
+func TestSetLazyGo(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var computed int32
+	mgr.SetLazy("key", func() interface{} {
+		atomic.AddInt32(&computed, 1)
+		return "val"
+	}, func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			Go(func() {
+				defer wg.Done()
+				for i := 0; i < 2; i++ {
+					if val, _ := mgr.GetValue("key"); val != "val" {
+						t.Errorf("expected child to see val, got %v", val)
+					}
+				}
+			})
+		}
+		if val, _ := mgr.GetValue("key"); val != "val" {
+			t.Errorf("expected parent to see val, got %v", val)
+		}
+		wg.Wait()
+	})
+	if computed != 1 {
+		t.Fatalf("expected a single computation across goroutines, got %d",
+			computed)
+	}
+}
+
+func TestSetLazyWithValues(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetLazy("key", func() interface{} { return "val" }, func() {
+		ctx := WithValues(context.Background(), mgr.Snapshot())
+		if val := ctx.Value("key"); val != "val" {
+			t.Fatalf("expected context to compute lazy value, got %v", val)
+		}
+	})
+}
+
+func TestSnapshotResolves(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetLazy("lazy", func() interface{} { return "computed" }, func() {
+		mgr.SetCompressed("compressed", []byte("data"), func() {
+			values := mgr.Snapshot()
+			if values["lazy"] != "computed" {
+				t.Fatalf("expected the lazy value computed, got %v", values["lazy"])
+			}
+			if data, _ := values["compressed"].([]byte); string(data) != "data" {
+				t.Fatalf("expected the data decompressed, got %v",
+					values["compressed"])
+			}
+		})
+	})
+}
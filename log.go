@@ -0,0 +1,73 @@
+package gls
+
+import (
+	"log"
+	"runtime"
+	"sync/atomic"
+)
+
+var (
+	logFunc     atomic.Value // func(format string, args ...interface{})
+	warnEmptyGo int32
+)
+
+// SetLogf sets the function used to report warnings and diagnostics, such as
+// those enabled by WarnOnEmptyGo. By default they go to log.Printf. Passing
+// nil restores the default.
+func SetLogf(logf func(format string, args ...interface{})) {
+	logFunc.Store(logf)
+}
+
+func logf(format string, args ...interface{}) {
+	if fn, _ := logFunc.Load().(func(string, ...interface{})); fn != nil {
+		fn(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// WarnOnEmptyGo controls whether Go logs a warning, through the function set
+// with SetLogf, when called from a goroutine that has no values on any
+// registered ContextManager. That usually means propagation broke somewhere
+// further up, such as a goroutine started with the 'go' keyword instead of
+// Go, and the new goroutine will silently get no context either. It is off by
+// default, and costs a single atomic load per Go call while off. It is meant
+// for development; Go calls within WithoutPropagation never warn.
+func WarnOnEmptyGo(enabled bool) {
+	var flag int32
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&warnEmptyGo, flag)
+}
+
+// warnIfEmptyGo is called by Go when WarnOnEmptyGo is enabled.
+func warnIfEmptyGo() {
+	if gid, ok := GetGoroutineId(); ok {
+		flags := goroutineFlags.state(gid)
+		if _, ok := flags[noPropagationKey{}]; ok {
+			return
+		}
+		if mgrs, ok := flags[goManagersKey{}].([]*ContextManager); ok {
+			for _, mgr := range mgrs {
+				if len(mgr.state(gid)) > 0 {
+					return
+				}
+			}
+		} else {
+			mgrRegistryMtx.RLock()
+			defer mgrRegistryMtx.RUnlock()
+			for mgr := range mgrRegistry {
+				if len(mgr.state(gid)) > 0 {
+					return
+				}
+			}
+		}
+	}
+	if _, file, line, ok := runtime.Caller(2); ok {
+		logf("gls: Go called from %s:%d on a goroutine without any context",
+			file, line)
+		return
+	}
+	logf("gls: Go called on a goroutine without any context")
+}
@@ -0,0 +1,59 @@
+package gls
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureLogs redirects gls logging until the returned function is called,
+// which returns everything logged in the meantime.
+func captureLogs() (stop func() []string) {
+	var mtx sync.Mutex
+	var logs []string
+	SetLogf(func(format string, args ...interface{}) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+	return func() []string {
+		SetLogf(nil)
+		mtx.Lock()
+		defer mtx.Unlock()
+		return logs
+	}
+}
+
+func TestWarnOnEmptyGo(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	stop := captureLogs()
+	var wg sync.WaitGroup
+	spawn := func() {
+		wg.Add(1)
+		Go(wg.Done)
+		wg.Wait()
+	}
+
+	spawn()
+	WarnOnEmptyGo(true)
+	mgr.SetValues(Values{"key": "val"}, spawn)
+	WithoutPropagation(spawn)
+	unregistered := newContextManager(Option{})
+	unregistered.SetValues(Values{"key": "val"}, func() {
+		WithGoManagers([]*ContextManager{unregistered}, spawn)
+	})
+	spawn()
+	WarnOnEmptyGo(false)
+	spawn()
+
+	logs := stop()
+	if len(logs) != 1 {
+		t.Fatalf("expected a single warning, got %v", logs)
+	}
+	if !strings.Contains(logs[0], "log_test.go") {
+		t.Fatalf("expected warning to name the calling file, got %q", logs[0])
+	}
+}
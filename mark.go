@@ -0,0 +1,56 @@
+package gls
+
+import (
+	"reflect"
+)
+
+// Mark is a record of the values a ContextManager had on some goroutine at
+// some point, obtained with Mark and compared against with Diff.
+type Mark struct {
+	values Values
+}
+
+// Mark records the current goroutine's values, for a later Diff to compare
+// against. It is a diagnostic, for instance for finding middleware that adds
+// unexpected keys while a request is processed.
+func (m *ContextManager) Mark() Mark {
+	return Mark{values: m.Snapshot()}
+}
+
+// Diff reports how the current goroutine's values differ from those recorded
+// by mark: keys that have been set since, keys that are no longer set, and
+// keys whose value has been replaced. Values are compared with ==, or with
+// reflect.DeepEqual if their type doesn't support it, so changes made inside
+// a value, such as a map modified in place, are not reported. Each slice is
+// sorted as SortedKeys sorts by default.
+func (m *ContextManager) Diff(mark Mark) (added, removed, changed []interface{}) {
+	current := m.Snapshot()
+	for key, val := range current {
+		old, ok := mark.values[key]
+		if !ok {
+			added = append(added, key)
+		} else if !sameValue(old, val) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range mark.values {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sortKeys(added, nil)
+	sortKeys(removed, nil)
+	sortKeys(changed, nil)
+	return added, removed, changed
+}
+
+// sameValue reports whether a and b are equal, falling back to
+// reflect.DeepEqual for types == can't compare.
+func sameValue(a, b interface{}) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = reflect.DeepEqual(a, b)
+		}
+	}()
+	return a == b
+}
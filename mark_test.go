@@ -0,0 +1,44 @@
+package gls
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	check := func(name string, got []interface{}, expected ...interface{}) {
+		if fmt.Sprintf("%v", got) != fmt.Sprintf("%v", expected) {
+			t.Fatalf("expected %s keys %v, got %v", name, expected, got)
+		}
+	}
+
+	mgr.SetValues(Values{"same": 1, "changed": 1, "removed": 1,
+		"slice": []int{1}}, func() {
+		mark := mgr.Mark()
+		added, removed, changed := mgr.Diff(mark)
+		check("added", added)
+		check("removed", removed)
+		check("changed", changed)
+
+		mgr.SwapValues(Values{"same": 1, "changed": 2, "slice": []int{1},
+			"b": 1, "a": 1})
+		added, removed, changed = mgr.Diff(mark)
+		check("added", added, "a", "b")
+		check("removed", removed, "removed")
+		check("changed", changed, "changed")
+
+		mgr.SwapValues(Values{"same": 1, "changed": 1, "removed": 1,
+			"slice": []int{2}})
+		added, removed, changed = mgr.Diff(mark)
+		check("added", added)
+		check("removed", removed)
+		check("changed", changed, "slice")
+	})
+
+	added, removed, _ := mgr.Diff(Mark{})
+	check("added", added)
+	check("removed", removed)
+}
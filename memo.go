@@ -0,0 +1,35 @@
+package gls
+
+type memoResult struct {
+	value interface{}
+	err   error
+}
+
+// Do calls fn and returns its results the first time it is called with key
+// on the current goroutine, and returns those same results, without calling
+// fn, every time after that, whether fn succeeded or not. It deduplicates
+// expensive operations that several code paths handling the same request
+// would otherwise each perform.
+//
+// Results are remembered until the outermost SetValues scope of this
+// ContextManager on the current goroutine exits, so they last for the
+// request rather than the process. Like errors recorded with AppendError,
+// they are local to the goroutine: a goroutine started by Go starts with
+// nothing remembered. Outside of any scope there is nowhere to remember
+// results, and fn is called every time. If fn panics, nothing is remembered.
+func (m *ContextManager) Do(key string, fn func() (interface{}, error)) (
+	interface{}, error) {
+	meta := m.currentMeta(true)
+	if meta == nil {
+		return fn()
+	}
+	if result, ok := meta.memo[key]; ok {
+		return result.value, result.err
+	}
+	value, err := fn()
+	if meta.memo == nil {
+		meta.memo = make(map[string]memoResult)
+	}
+	meta.memo[key] = memoResult{value: value, err: err}
+	return value, err
+}
@@ -0,0 +1,61 @@
+package gls
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	calls := make(map[string]int)
+	failure := errors.New("failure")
+	lookup := func(key string, err error) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			calls[key]++
+			return key + " result", err
+		}
+	}
+
+	if _, err := mgr.Do("user", lookup("user", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mgr.Do("user", lookup("user", nil))
+	if calls["user"] != 2 {
+		t.Fatalf("expected no caching outside of a scope, got %d calls",
+			calls["user"])
+	}
+
+	mgr.SetValues(Values{"request": "1"}, func() {
+		for i := 0; i < 3; i++ {
+			val, err := mgr.Do("profile", lookup("profile", nil))
+			if val != "profile result" || err != nil {
+				t.Fatalf("expected cached profile result, got %v (%v)", val, err)
+			}
+			mgr.SetValues(Values{"nested": i}, func() {
+				if _, err := mgr.Do("quota", lookup("quota", failure)); err != failure {
+					t.Fatalf("expected cached failure, got %v", err)
+				}
+			})
+		}
+
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			mgr.Do("profile", lookup("profile", nil))
+		})
+		<-done
+	})
+	if calls["profile"] != 2 || calls["quota"] != 1 {
+		t.Fatalf("expected 2 profile calls (one per goroutine) and 1 quota call, "+
+			"got %v", calls)
+	}
+
+	mgr.SetValues(Values{"request": "2"}, func() {
+		mgr.Do("quota", lookup("quota", failure))
+	})
+	if calls["quota"] != 2 {
+		t.Fatalf("expected a new scope to start with nothing cached")
+	}
+}
@@ -0,0 +1,56 @@
+package gls
+
+import (
+	"unsafe"
+)
+
+// rough costs used by ApproxMemoryBytes, for a 64-bit platform
+const (
+	approxSlotBytes  = int(2*unsafe.Sizeof(uintptr(0))) + 8 + 4 // values, meta, generations and depths
+	approxMapBytes   = 48                                       // map header
+	approxEntryBytes = 40                                       // key and value interfaces plus bucket overhead
+)
+
+// ApproxMemoryBytes returns a rough estimate of the memory held by this
+// ContextManager: its per-goroutine slots, the maps holding each goroutine's
+// values, and a shallow size of each key and value where one can be
+// determined (the contents of strings and byte slices, and boxed scalars).
+// Memory referenced by other values, such as pointers or structs, is not
+// counted, so the estimate is a lower bound useful for spotting growth rather
+// than an exact figure.
+//
+// ApproxMemoryBytes walks every goroutine's values, blocking SetValues calls
+// on this ContextManager while it does so. It is a diagnostic and should not
+// be called on hot paths.
+func (m *ContextManager) ApproxMemoryBytes() int {
+	m.extendLock.Lock()
+	defer m.extendLock.Unlock()
+
+	total := len(m.values) * approxSlotBytes
+	for _, state := range m.values {
+		if state == nil {
+			continue
+		}
+		total += approxMapBytes + len(state)*approxEntryBytes
+		for key, val := range state {
+			total += approxShallowBytes(key) + approxShallowBytes(val)
+		}
+	}
+	return total
+}
+
+func approxShallowBytes(v interface{}) int {
+	switch v := v.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return cap(v)
+	case int, int64, uint, uint64, float64, complex64:
+		return 8
+	case int32, uint32, float32:
+		return 4
+	case complex128:
+		return 16
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package gls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApproxMemoryBytes(t *testing.T) {
+	mgr := NewContextManager(Option{InitialMaxGoroutineCount: 16})
+	defer mgr.Unregister()
+
+	empty := mgr.ApproxMemoryBytes()
+	if empty != 16*approxSlotBytes {
+		t.Fatalf("expected empty manager to count only its slots, got %d", empty)
+	}
+
+	mgr.SetValues(Values{"key": 1}, func() {
+		small := mgr.ApproxMemoryBytes()
+		if small <= empty {
+			t.Fatalf("expected values to add to the estimate, got %d <= %d",
+				small, empty)
+		}
+		mgr.SetValues(Values{"blob": strings.Repeat("x", 4096)}, func() {
+			if large := mgr.ApproxMemoryBytes(); large < small+4096 {
+				t.Fatalf("expected string contents to be counted, got %d < %d",
+					large, small+4096)
+			}
+		})
+	})
+
+	if after := mgr.ApproxMemoryBytes(); after != empty {
+		t.Fatalf("expected estimate to return to %d, got %d", empty, after)
+	}
+}
+
+func TestApproxMemoryBytesConcurrent(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			mgr.SetValues(Values{"key": i}, func() {
+				mgr.SetValues(Values{"key2": i}, func() {})
+			})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			mgr.ApproxMemoryBytes()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package gls
+
+// goroutineMeta holds bookkeeping a ContextManager keeps for a goroutine
+// alongside its values. Unlike values, it is never visible through GetValue,
+// and only mutexes are handed on to goroutines started by Go. It is dropped
+// when the goroutine's values are released.
+type goroutineMeta struct {
+	errors  []error
+	history setHistory
+	frames  []debugFrame
+	reads   map[interface{}]uint64 // GetValue hits by key, for Option.Debug
+	// panicking is set once PanicHandler has seen panicValue, so that
+	// enclosing scopes the same panic unwinds through don't report it again
+	panicking  bool
+	panicValue interface{}
+	// done holds the channels handed out by Done, by scope depth
+	done map[uint32]chan struct{}
+	memo map[string]memoResult
+	// watchers holds the callbacks registered with Watch, by key
+	watchers map[interface{}][]func(old, new interface{})
+	// mutexes holds the mutexes handed out by Mutex, shared with children
+	mutexes *mutexSet
+}
+
+// getMeta returns the metadata for gid, creating it if create is true. It
+// returns nil if the goroutine has no values on this ContextManager, as there
+// would be nothing to clean the metadata up again.
+func (m *ContextManager) getMeta(gid uint32, create bool) *goroutineMeta {
+	m.extendLock.RLock()
+	defer m.extendLock.RUnlock()
+	if gid >= uint32(len(m.values)) || m.values[gid] == nil {
+		return nil
+	}
+	meta := m.meta[gid]
+	if meta == nil && create {
+		meta = &goroutineMeta{}
+		m.meta[gid] = meta
+	}
+	return meta
+}
+
+func (m *ContextManager) currentMeta(create bool) *goroutineMeta {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return nil
+	}
+	return m.getMeta(gid, create)
+}
@@ -0,0 +1,59 @@
+package gls
+
+import (
+	"sync"
+)
+
+type mutexSet struct {
+	mtx   sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// Mutex returns the mutex called name for the current goroutine's values,
+// creating it on first use, so goroutines handling the same request can
+// coordinate access to a resource belonging to that request without a
+// process-wide lock map keyed by request id. The mutexes are kept by
+// reference, so goroutines started with Go after the first Mutex call in a
+// scope share them with their parent, and with each other.
+//
+// The mutexes are kept alongside the current goroutine's values, not among
+// them, so they don't show up in Snapshot or the like, until its outermost
+// scope on this ContextManager exits. Children only share mutexes that existed, in
+// name or not, when they were started: if neither the parent nor an ancestor
+// called Mutex beforehand, each child creates its own, so call Mutex once in
+// the parent before starting the goroutines that coordinate with it. Outside
+// any scope, Mutex returns a new mutex on every call, as there is nowhere to
+// keep it.
+func (m *ContextManager) Mutex(name string) *sync.Mutex {
+	meta := m.currentMeta(true)
+	if meta == nil {
+		return new(sync.Mutex)
+	}
+	if meta.mutexes == nil {
+		meta.mutexes = &mutexSet{locks: make(map[string]*sync.Mutex)}
+	}
+	mutexes := meta.mutexes
+	mutexes.mtx.Lock()
+	defer mutexes.mtx.Unlock()
+	lock := mutexes.locks[name]
+	if lock == nil {
+		lock = new(sync.Mutex)
+		mutexes.locks[name] = lock
+	}
+	return lock
+}
+
+// inheritMutexes calls fn with mutexes as the current goroutine's mutex set,
+// as handed on by Go, putting back any set it had before once fn returns. It
+// must be called within a scope on m.
+func (m *ContextManager) inheritMutexes(mutexes *mutexSet, fn func()) {
+	meta := m.currentMeta(true)
+	if meta == nil {
+		fn()
+		return
+	}
+	old := meta.mutexes
+	meta.mutexes = mutexes
+	defer func() { meta.mutexes = old }()
+	fn()
+}
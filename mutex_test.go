@@ -0,0 +1,113 @@
+package gls
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMutex(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"request": "1"}, func() {
+		lock := mgr.Mutex("resource")
+		if mgr.Mutex("resource") != lock {
+			t.Fatalf("expected the same mutex for the same name")
+		}
+		if mgr.Mutex("other") == lock {
+			t.Fatalf("expected a different mutex for a different name")
+		}
+
+		var (
+			wg      sync.WaitGroup
+			holders int
+			counter int
+		)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			Go(func() {
+				defer wg.Done()
+				child := mgr.Mutex("resource")
+				if child != lock {
+					t.Errorf("expected children to share the parent's mutex")
+					return
+				}
+				for j := 0; j < 1000; j++ {
+					child.Lock()
+					holders++
+					if holders != 1 {
+						t.Errorf("expected one holder at a time, got %d", holders)
+					}
+					counter++
+					holders--
+					child.Unlock()
+				}
+			})
+		}
+		wg.Wait()
+		if counter != 2000 {
+			t.Fatalf("expected 2000 increments, got %d", counter)
+		}
+	})
+
+	// another request gets its own mutexes
+	mgr.SetValues(Values{"request": "2"}, func() {
+		first := mgr.Mutex("resource")
+		mgr.SetValues(Values{"request": "3"}, func() {
+			if mgr.Mutex("resource") != first {
+				t.Fatalf("expected nested scopes to share the mutex")
+			}
+		})
+	})
+	if mgr.Mutex("resource") == mgr.Mutex("resource") {
+		t.Fatalf("expected a new mutex on every call outside a scope")
+	}
+}
+
+func TestMutexHidden(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"request": "1"}, func() {
+		mark := mgr.Mark()
+		mgr.Mutex("resource")
+		if n := mgr.Len(); n != 1 {
+			t.Fatalf("expected Mutex not to add keys, got %d", n)
+		}
+		if values := mgr.Snapshot(); len(values) != 1 {
+			t.Fatalf("expected only request in the Snapshot, got %v", values)
+		}
+		if added, removed, changed := mgr.Diff(mark); len(added)+len(removed)+
+			len(changed) != 0 {
+			t.Fatalf("expected no differences, got %v, %v, %v",
+				added, removed, changed)
+		}
+		mgr.Freeze(func() {
+			mgr.Mutex("within freeze")
+		})
+	})
+}
+
+func TestMutexWrapRestores(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var parent *sync.Mutex
+	var wrapped func()
+	mgr.SetValues(Values{"request": "1"}, func() {
+		parent = mgr.Mutex("resource")
+		wrapped = Wrap(func() {
+			if mgr.Mutex("resource") != parent {
+				t.Errorf("expected the wrapped callback to share the mutex")
+			}
+		})
+	})
+
+	mgr.SetValues(Values{"worker": "1"}, func() {
+		own := mgr.Mutex("resource")
+		wrapped()
+		if mgr.Mutex("resource") != own {
+			t.Fatalf("expected the worker's own mutex back")
+		}
+	})
+}
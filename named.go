@@ -0,0 +1,41 @@
+package gls
+
+import (
+	"sync"
+)
+
+var (
+	namedMgrs    = make(map[string]*ContextManager)
+	namedMgrsMtx sync.Mutex
+)
+
+// GetOrCreateManager returns the ContextManager registered under name,
+// creating it with option, and registering it as NewContextManager does, if
+// there is none. This lets packages that should share a ContextManager, such
+// as plugins, each ask for it by name without coordinating which of them
+// creates it. option is only used by the call that creates the
+// ContextManager; later calls get the existing one whatever option they pass.
+// Like NewContextManager, it panics if option is invalid.
+//
+// A named ContextManager keeps its name until it is unregistered, after which
+// the next GetOrCreateManager call for the name creates a new one.
+func GetOrCreateManager(name string, option Option) *ContextManager {
+	namedMgrsMtx.Lock()
+	defer namedMgrsMtx.Unlock()
+	if mgr := namedMgrs[name]; mgr != nil {
+		return mgr
+	}
+	mgr := NewContextManager(option)
+	mgr.name = name
+	namedMgrs[name] = mgr
+	return mgr
+}
+
+// forgetName removes m from the named ContextManagers, if it is one.
+func (m *ContextManager) forgetName() {
+	namedMgrsMtx.Lock()
+	defer namedMgrsMtx.Unlock()
+	if m.name != "" && namedMgrs[m.name] == m {
+		delete(namedMgrs, m.name)
+	}
+}
@@ -0,0 +1,47 @@
+package gls
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGetOrCreateManager(t *testing.T) {
+	first := GetOrCreateManager("test-shared", Option{Debug: true})
+	defer first.Unregister()
+	if second := GetOrCreateManager("test-shared", Option{}); second != first {
+		t.Fatalf("expected the same manager for the same name")
+	}
+	if !first.debug {
+		t.Fatalf("expected the first call's Option to be used")
+	}
+	other := GetOrCreateManager("test-other", Option{})
+	defer other.Unregister()
+	if other == first {
+		t.Fatalf("expected a different manager for a different name")
+	}
+
+	// concurrent callers agree on a single instance
+	var wg sync.WaitGroup
+	mgrs := make([]*ContextManager, 8)
+	for i := range mgrs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mgrs[i] = GetOrCreateManager("test-concurrent", Option{})
+		}(i)
+	}
+	wg.Wait()
+	defer mgrs[0].Unregister()
+	for _, mgr := range mgrs {
+		if mgr != mgrs[0] {
+			t.Fatalf("expected concurrent calls to return the same manager")
+		}
+	}
+
+	first.Unregister()
+	if again := GetOrCreateManager("test-shared", Option{}); again == first {
+		t.Fatalf("expected a new manager once the old one was unregistered")
+	} else {
+		again.Unregister()
+	}
+}
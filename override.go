@@ -0,0 +1,59 @@
+package gls
+
+// Override sets key to value in the current goroutine's values in place,
+// rather than in a new SetValues scope, and returns a revert function that
+// puts back the key's previous value, or removes it if it had none. It saves
+// the closure and Values map SetValues needs for briefly flipping a single
+// value around a sub-operation:
+//
+//	defer mgr.Override("dry-run", true)()
+//
+// Override mutates the innermost scope's values, so the caller must call
+// revert, ideally through defer, before that scope exits, and before any
+// Override or SetValues scope entered after it ends, or the reverted value
+// leaks into the wrong scope. Calling revert again does nothing. Like
+// SetValues, Override panics within Freeze and respects Pin; it does nothing,
+// and returns a revert function that does nothing, for a pinned key under
+// PinIgnore or if the goroutine has no values on this ContextManager.
+func (m *ContextManager) Override(key, value interface{}) (revert func()) {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return func() {}
+	}
+	key = m.normalizeKey(key)
+	if flags := goroutineFlags.state(gid); flags != nil {
+		if _, frozen := flags[frozenKey{mgr: m}]; frozen {
+			panic("gls: Override called within Freeze")
+		}
+		if len(m.unpinned(flags, Values{key: value})) == 0 {
+			return func() {}
+		}
+	}
+
+	m.extendLock.RLock()
+	if gid >= uint32(len(m.values)) || m.values[gid] == nil {
+		m.extendLock.RUnlock()
+		return func() {}
+	}
+	state := m.values[gid]
+	old, existed := state[key]
+	state[key] = value
+	m.bumpGeneration(gid)
+	m.extendLock.RUnlock()
+
+	var reverted bool
+	return func() {
+		if reverted {
+			return
+		}
+		reverted = true
+		m.extendLock.RLock()
+		defer m.extendLock.RUnlock()
+		if existed {
+			state[key] = old
+		} else {
+			delete(state, key)
+		}
+		m.bumpGeneration(gid)
+	}
+}
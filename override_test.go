@@ -0,0 +1,50 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestOverride(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetValues(Values{"mode": "live"}, func() {
+		revert := mgr.Override("mode", "dry-run")
+		if val, _ := mgr.GetValue("mode"); val != "dry-run" {
+			t.Fatalf("expected dry-run, got %v", val)
+		}
+		revert()
+		revert()
+		if val, _ := mgr.GetValue("mode"); val != "live" {
+			t.Fatalf("expected live after revert, got %v", val)
+		}
+
+		func() {
+			defer mgr.Override("absent", 1)()
+			if val, _ := mgr.GetValue("absent"); val != 1 {
+				t.Fatalf("expected 1, got %v", val)
+			}
+		}()
+		if _, ok := mgr.GetValue("absent"); ok {
+			t.Fatalf("expected absent to be removed again")
+		}
+	})
+
+	// nothing to override outside a scope
+	mgr.Override("mode", "dry-run")()
+	if _, ok := mgr.GetValue("mode"); ok {
+		t.Fatalf("expected no value outside a scope")
+	}
+}
+
+func TestOverridePinned(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.Pin("mode", "live", func() {
+		defer mgr.Override("mode", "dry-run")()
+		if val, _ := mgr.GetValue("mode"); val != "live" {
+			t.Fatalf("expected pinned value to stay, got %v", val)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package gls
+
+// handlePanic calls the PanicHandler for a panic unwinding through a scope on
+// gid, unless an inner scope already did.
+func (m *ContextManager) handlePanic(gid uint32, recovered interface{}) {
+	meta := m.getMeta(gid, true)
+	if meta == nil {
+		return
+	}
+	if meta.panicking && sameValue(meta.panicValue, recovered) {
+		return
+	}
+	meta.panicking, meta.panicValue = true, recovered
+	m.panicHandler(recovered, m.Snapshot())
+}
+
+// panicHandled forgets a reported panic once a scope on gid exits normally,
+// meaning something recovered from it.
+func (m *ContextManager) panicHandled(gid uint32) {
+	if meta := m.getMeta(gid, false); meta != nil && meta.panicking {
+		meta.panicking, meta.panicValue = false, nil
+	}
+}
@@ -0,0 +1,67 @@
+package gls
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPanicHandler(t *testing.T) {
+	type report struct {
+		recovered interface{}
+		values    Values
+	}
+	var reports []report
+	mgr := NewContextManager(Option{
+		PanicHandler: func(recovered interface{}, v Values) {
+			reports = append(reports, report{recovered, v})
+		},
+	})
+	defer mgr.Unregister()
+
+	boom := errors.New("boom")
+	catch := func(fn func()) (recovered interface{}) {
+		defer func() { recovered = recover() }()
+		fn()
+		return nil
+	}
+
+	recovered := catch(func() {
+		mgr.SetValues(Values{"request": "1"}, func() {
+			mgr.SetValues(Values{"user": "bob"}, func() {
+				panic(boom)
+			})
+		})
+	})
+	if recovered != boom {
+		t.Fatalf("expected the panic to propagate, got %v", recovered)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("expected a single report, got %d", len(reports))
+	}
+	if r := reports[0]; r.recovered != boom || r.values["request"] != "1" ||
+		r.values["user"] != "bob" {
+		t.Fatalf("expected boom with both values, got %v", r)
+	}
+	if _, ok := mgr.GetValue("request"); ok {
+		t.Fatalf("expected values to be restored after the panic")
+	}
+
+	// a recovered panic doesn't hide a later one with the same value
+	mgr.SetValues(Values{"request": "2"}, func() {
+		catch(func() {
+			mgr.SetValues(Values{"user": "alice"}, func() { panic(boom) })
+		})
+		mgr.SetValues(Values{"step": "retry"}, func() {})
+		catch(func() {
+			mgr.SetValues(Values{"user": "carol"}, func() { panic(boom) })
+		})
+	})
+	if len(reports) != 3 || reports[2].values["user"] != "carol" {
+		t.Fatalf("expected a report per panic, got %v", reports)
+	}
+
+	mgr.SetValues(Values{"request": "3"}, func() {})
+	if len(reports) != 3 {
+		t.Fatalf("expected no report without a panic")
+	}
+}
@@ -0,0 +1,76 @@
+package gls
+
+// Policy determines how a key's value crosses into goroutines started with
+// Go, and everything else that hands values to another goroutine (Wrap,
+// GoSlice, Capture, Attach and the like). Keys without a policy are shared,
+// with values implementing Cloner copied on write; see Cloner.
+type Policy int
+
+const (
+	// PolicyShare hands the child the same value, without copy on write
+	// even if it implements Cloner. It suits values that are never
+	// modified, such as configuration or loggers.
+	PolicyShare Policy = iota + 1
+	// PolicyCopy hands the child its own copy of the value, made with
+	// Clone as the child is started. Values that don't implement Cloner
+	// are shared as usual.
+	PolicyCopy
+	// PolicyDrop keeps the value from crossing at all: the child starts
+	// without the key set.
+	PolicyDrop
+)
+
+// SetPropagationPolicy sets the Policy for key, replacing any previous one.
+// Passing a Policy of zero restores the default. Policies apply to this
+// ContextManager only, and are meant to be set up front, alongside the
+// ContextManager itself.
+func (m *ContextManager) SetPropagationPolicy(key interface{}, policy Policy) {
+	key = m.normalizeKey(key)
+	m.policiesMtx.Lock()
+	defer m.policiesMtx.Unlock()
+	old, _ := m.policies.Load().(map[interface{}]Policy)
+	policies := make(map[interface{}]Policy, len(old)+1)
+	for k, p := range old {
+		policies[k] = p
+	}
+	if policy == 0 {
+		delete(policies, key)
+	} else {
+		policies[key] = policy
+	}
+	m.policies.Store(policies)
+}
+
+// propagate prepares values, a private copy of some goroutine's values, to
+// be handed to another goroutine, applying policies and
+// Option.ChildIDDerive.
+func (m *ContextManager) propagate(values Values) Values {
+	policies, _ := m.policies.Load().(map[interface{}]Policy)
+	for key, val := range values {
+		switch policies[key] {
+		case PolicyShare:
+			if shared, ok := val.(*sharedValue); ok {
+				values[key] = shared.value
+			}
+		case PolicyCopy:
+			if shared, ok := val.(*sharedValue); ok {
+				val = shared.value
+			}
+			if cloner, ok := val.(Cloner); ok {
+				values[key] = cloner.Clone()
+			}
+		case PolicyDrop:
+			delete(values, key)
+		default:
+			if _, ok := val.(Cloner); ok {
+				values[key] = &sharedValue{value: val}
+			}
+		}
+	}
+	if m.childIDDerive != nil {
+		if parent, ok := values[m.childIDKey]; ok {
+			values[m.childIDKey] = m.childIDDerive(peekValue(parent))
+		}
+	}
+	return values
+}
@@ -0,0 +1,45 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestSetPropagationPolicy(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	mgr.SetPropagationPolicy("shared", PolicyShare)
+	mgr.SetPropagationPolicy("copied", PolicyCopy)
+	mgr.SetPropagationPolicy("dropped", PolicyDrop)
+	mgr.SetPropagationPolicy("reset", PolicyDrop)
+	mgr.SetPropagationPolicy("reset", 0)
+
+	shared, copied := counters{"hits": 0}, counters{"hits": 0}
+	mgr.SetValues(Values{"shared": shared, "copied": copied,
+		"dropped": "secret", "reset": "val"}, func() {
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			if val, _ := mgr.GetValueForWrite("shared"); val != nil {
+				val.(counters)["hits"]++
+			}
+			if val, _ := mgr.GetValue("copied"); val != nil {
+				val.(counters)["hits"]++
+			}
+			if val, ok := mgr.GetValue("dropped"); ok {
+				t.Errorf("expected dropped key not to propagate, got %v", val)
+			}
+			if val, _ := mgr.GetValue("reset"); val != "val" {
+				t.Errorf("expected default policy after reset, got %v", val)
+			}
+		})
+		<-done
+	})
+
+	if shared["hits"] != 1 {
+		t.Fatalf("expected shared value to be modified by the child")
+	}
+	if copied["hits"] != 0 {
+		t.Fatalf("expected child to modify its own copy")
+	}
+}
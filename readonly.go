@@ -0,0 +1,39 @@
+package gls
+
+// ReadOnlyManager is a view of a ContextManager that can read values but not
+// set them, for handing to plugins and other code that should see the
+// current context without being able to change it. Its zero value has no
+// values.
+type ReadOnlyManager struct {
+	m *ContextManager
+}
+
+// ReadOnly returns a read-only view of m. The view is live: it reads whatever
+// values m has on the goroutine it is used on, so it can be created once and
+// shared.
+func (m *ContextManager) ReadOnly() ReadOnlyManager {
+	return ReadOnlyManager{m: m}
+}
+
+// GetValue is ContextManager.GetValue.
+func (r ReadOnlyManager) GetValue(key interface{}) (value interface{}, ok bool) {
+	if r.m == nil {
+		return nil, false
+	}
+	return r.m.GetValue(key)
+}
+
+// Has reports whether GetValue would find a value for key.
+func (r ReadOnlyManager) Has(key interface{}) bool {
+	_, ok := r.GetValue(key)
+	return ok
+}
+
+// Keys returns the keys set on the current goroutine, ordered as
+// ContextManager.SortedKeys orders them by default.
+func (r ReadOnlyManager) Keys() []interface{} {
+	if r.m == nil {
+		return nil
+	}
+	return r.m.SortedKeys(nil)
+}
@@ -0,0 +1,47 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	view := mgr.ReadOnly()
+	if view.Has("user") || view.Keys() != nil {
+		t.Fatalf("expected an empty view outside any scope")
+	}
+	mgr.SetValues(Values{"user": "bob", "role": "admin"}, func() {
+		if val, ok := view.GetValue("user"); !ok || val != "bob" {
+			t.Fatalf("expected bob, got %v", val)
+		}
+		mgr.SetValues(Values{"user": "alice"}, func() {
+			if val, _ := view.GetValue("user"); val != "alice" {
+				t.Fatalf("expected the view to be live, got %v", val)
+			}
+		})
+		if keys := view.Keys(); len(keys) != 2 || keys[0] != "role" {
+			t.Fatalf("expected keys [role user], got %v", keys)
+		}
+		if view.Has("missing") {
+			t.Fatalf("expected missing not to be set")
+		}
+	})
+
+	// the view itself offers no way to set values
+	var plugin interface{} = view
+	if _, ok := plugin.(interface {
+		SetValues(Values, func())
+	}); ok {
+		t.Fatalf("expected ReadOnlyManager to have no SetValues")
+	}
+	if _, ok := plugin.(*ContextManager); ok {
+		t.Fatalf("expected ReadOnlyManager not to be a ContextManager")
+	}
+
+	var zero ReadOnlyManager
+	if zero.Has("user") || zero.Keys() != nil {
+		t.Fatalf("expected the zero view to be empty")
+	}
+}
@@ -0,0 +1,46 @@
+package gls
+
+// MissReason says why GetValueReason did not find a key set.
+type MissReason int
+
+const (
+	// MissNone means the key was set.
+	MissNone MissReason = iota
+	// MissNoGoroutineID means the current goroutine has no identifier: it
+	// has never been inside a SetValues scope of any ContextManager, or was
+	// started with the go keyword rather than Go.
+	MissNoGoroutineID
+	// MissNoState means the goroutine has an identifier but no values on
+	// this ContextManager.
+	MissNoState
+	// MissKeyAbsent means the goroutine has values on this ContextManager,
+	// but not for the key.
+	MissKeyAbsent
+)
+
+func (r MissReason) String() string {
+	switch r {
+	case MissNone:
+		return "found"
+	case MissNoGoroutineID:
+		return "no goroutine id"
+	case MissNoState:
+		return "no state"
+	case MissKeyAbsent:
+		return "key absent"
+	}
+	return "unknown"
+}
+
+// GetValueReason is GetValue, additionally reporting which step of the lookup
+// failed, for diagnosing values that unexpectedly vanish. reason is MissNone
+// if key was set. If it wasn't, reason says why even when a default is
+// returned, with ok set to true, as for GetValue.
+func (m *ContextManager) GetValueReason(key interface{}) (
+	value interface{}, ok bool, reason MissReason) {
+	if value, reason = m.lookupReason(key); reason == MissNone {
+		return value, true, MissNone
+	}
+	value, ok = m.missing(key)
+	return value, ok, reason
+}
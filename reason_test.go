@@ -0,0 +1,44 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestGetValueReason(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	other := NewContextManager(Option{})
+	defer other.Unregister()
+
+	check := func(expected MissReason, expectedOk bool) {
+		t.Helper()
+		val, ok, reason := mgr.GetValueReason("key")
+		if reason != expected || ok != expectedOk {
+			t.Fatalf("expected %v, %t, got %v, %t (%v)", expected, expectedOk,
+				reason, ok, val)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		check(MissNoGoroutineID, false)
+	}()
+	<-done
+
+	other.SetValues(Values{"key": "other"}, func() {
+		check(MissNoState, false)
+	})
+	mgr.SetValues(Values{"unrelated": 1}, func() {
+		check(MissKeyAbsent, false)
+		mgr.SetValues(Values{"key": "val"}, func() {
+			check(MissNone, true)
+		})
+		mgr.SetDefaults(Values{"key": "default"})
+		check(MissKeyAbsent, true)
+	})
+
+	if MissKeyAbsent.String() != "key absent" {
+		t.Fatalf("expected a readable reason, got %v", MissKeyAbsent)
+	}
+}
@@ -0,0 +1,30 @@
+package gls
+
+import (
+	"reflect"
+)
+
+// CallWithContext calls fn with args, exactly as fn.Call(args) would. Calls
+// made through reflection run on the calling goroutine like any other call,
+// so every ContextManager's values are visible to fn without any help;
+// CallWithContext exists to make that intent explicit at dynamic dispatch
+// sites, such as plugin systems, and to document the contract.
+//
+// What reflection does not preserve is context in goroutines fn starts
+// itself with the 'go' keyword, which begin without any values like every
+// other such goroutine. Plugins should start goroutines with Go, and callbacks
+// a plugin keeps and calls later from goroutines of its own should be wrapped
+// with Wrap before they are handed to it.
+func CallWithContext(fn reflect.Value, args []reflect.Value) []reflect.Value {
+	return fn.Call(args)
+}
+
+// CallWithValues is like CallWithContext, but calls fn within a scope with v
+// set, as SetValues would.
+func (m *ContextManager) CallWithValues(v Values, fn reflect.Value,
+	args []reflect.Value) (results []reflect.Value) {
+	m.SetValues(v, func() {
+		results = fn.Call(args)
+	})
+	return results
+}
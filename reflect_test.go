@@ -0,0 +1,46 @@
+package gls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallWithContext(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	handler := reflect.ValueOf(func(key string) string {
+		val, _ := mgr.GetValueString(key)
+		return val
+	})
+	call := func(results []reflect.Value) string {
+		return results[0].Interface().(string)
+	}
+	args := []reflect.Value{reflect.ValueOf("plugin")}
+
+	mgr.SetValues(Values{"plugin": "outer"}, func() {
+		if val := call(CallWithContext(handler, args)); val != "outer" {
+			t.Fatalf("expected outer through reflection, got %s", val)
+		}
+		if val := call(mgr.CallWithValues(Values{"plugin": "inner"}, handler,
+			args)); val != "inner" {
+			t.Fatalf("expected inner through CallWithValues, got %s", val)
+		}
+		if val, _ := mgr.GetValue("plugin"); val != "outer" {
+			t.Fatalf("expected CallWithValues to restore outer, got %v", val)
+		}
+
+		spawning := reflect.ValueOf(func(done chan string) {
+			Go(func() {
+				val, _ := mgr.GetValueString("plugin")
+				done <- val
+			})
+		})
+		done := make(chan string)
+		CallWithContext(spawning, []reflect.Value{reflect.ValueOf(done)})
+		if val := <-done; val != "outer" {
+			t.Fatalf("expected a goroutine started with Go to keep context, got %s",
+				val)
+		}
+	})
+}
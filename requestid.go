@@ -0,0 +1,40 @@
+package gls
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// RequestIDKey is the key SetRequestID and RequestID use. It is exported so
+// that libraries sharing a ContextManager all agree on where the request id
+// lives, whichever of them set it.
+//
+// RequestIDKey, SetRequestID, RequestID and GenerateRequestID are a small
+// convenience built on SetValues and GetValue; nothing else in the package
+// depends on them.
+var RequestIDKey = GenSym()
+
+// SetRequestID calls fn with id set as the current request id on m. Like any
+// other value, it is propagated to goroutines started with Go.
+func SetRequestID(m *ContextManager, id string, fn func()) {
+	m.SetValues(Values{RequestIDKey: id}, fn)
+}
+
+// RequestID returns the request id set on m with SetRequestID. ok will be
+// false if there isn't one.
+func RequestID(m *ContextManager) (id string, ok bool) {
+	return m.GetValueString(RequestIDKey)
+}
+
+// GenerateRequestID returns a new random (version 4) UUID, such as
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479", for use with SetRequestID. It panics
+// if the system's secure random number generator fails.
+func GenerateRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("gls: generating request id: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,49 @@
+package gls
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if _, ok := RequestID(mgr); ok {
+		t.Fatalf("expected no request id outside of a scope")
+	}
+
+	id := GenerateRequestID()
+	SetRequestID(mgr, id, func() {
+		if got, _ := RequestID(mgr); got != id {
+			t.Fatalf("expected request id %s, got %s", id, got)
+		}
+		done := make(chan string)
+		Go(func() {
+			got, _ := RequestID(mgr)
+			done <- got
+		})
+		if got := <-done; got != id {
+			t.Fatalf("expected request id %s in child, got %s", id, got)
+		}
+	})
+	if _, ok := RequestID(mgr); ok {
+		t.Fatalf("expected no request id after scope exits")
+	}
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(
+		`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := GenerateRequestID()
+		if !uuid.MatchString(id) {
+			t.Fatalf("expected a version 4 UUID, got %s", id)
+		}
+		if seen[id] {
+			t.Fatalf("expected unique ids, got %s twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,42 @@
+package gls
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Require checks that every one of keys is set on m for the current
+// goroutine, so a function can assert the context it depends on at entry
+// instead of failing somewhere deep inside. It returns nil if they all are,
+// and otherwise an error naming the missing keys and the function that called
+// Require:
+//
+//	func charge(amount int) error {
+//		if err := gls.Require(mgr, userKey, tenantKey); err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// A key counts as set whenever GetValue would find it, including through a
+// default.
+func Require(m *ContextManager, keys ...interface{}) error {
+	var missing []string
+	for _, key := range keys {
+		if _, ok := m.GetValue(key); !ok {
+			missing = append(missing, fmt.Sprint(key))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	caller := "unknown function"
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			caller = fn.Name()
+		}
+	}
+	return fmt.Errorf("gls: missing required context keys %s in %s",
+		strings.Join(missing, ", "), caller)
+}
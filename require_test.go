@@ -0,0 +1,31 @@
+package gls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequire(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if err := Require(mgr); err != nil {
+		t.Fatalf("expected no error for no keys, got %v", err)
+	}
+	if err := Require(mgr, "user"); err == nil ||
+		err.Error() != "gls: missing required context keys user in "+
+			"github.com/HyungrakJo/gls.TestRequire" {
+		t.Fatalf("expected missing user without any context, got %v", err)
+	}
+
+	mgr.SetValues(Values{"user": "bob", "tenant": "acme"}, func() {
+		if err := Require(mgr, "user", "tenant"); err != nil {
+			t.Fatalf("expected all keys present, got %v", err)
+		}
+		err := Require(mgr, "user", "session", "tenant", "trace")
+		if err == nil || !strings.HasPrefix(err.Error(), "gls: missing required "+
+			"context keys session, trace in github.com/HyungrakJo/gls.TestRequire") {
+			t.Fatalf("expected session and trace to be missing, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package gls
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const isolatedEnv = "GLS_ISOLATED_TEST"
+
+// isolated reports whether the calling test runs in a test process of its
+// own, so that it may replace package globals, such as stackTagPool, or cap
+// them, without affecting other tests. In the original process it runs the
+// test again in a new process, fails if that does, and returns false:
+//
+//	if !isolated(t) {
+//		return
+//	}
+func isolated(t *testing.T) bool {
+	if os.Getenv(isolatedEnv) == t.Name() {
+		return true
+	}
+	cmd := exec.Command(os.Args[0], "-test.v",
+		"-test.run=^"+regexp.QuoteMeta(t.Name())+"$")
+	cmd.Env = append(os.Environ(), isolatedEnv+"="+t.Name())
+	out, err := cmd.CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "--- PASS: "+t.Name()) {
+		t.Fatalf("expected %s to pass in its own process, got %v:\n%s",
+			t.Name(), err, out)
+	}
+	return false
+}
+
+// resetPackageState puts the package's global state back as it was at
+// startup: a fresh identifier pool, zeroed ReadStats counters, no
+// process-wide hooks or settings, no named ContextManagers, an empty
+// registry, and a new DefaultManager that registers itself once used.
+// ContextManagers created before the reset keep working, but are no longer
+// propagated by Go. It must only be called from an isolated test, as
+// identifiers still held from the old pool would be handed out again, and
+// tests run after it would see different globals than those before.
+func resetPackageState() {
+	mgrRegistryMtx.Lock()
+	mgrRegistry = make(map[*ContextManager]bool)
+	mgrRegistryMtx.Unlock()
+	namedMgrsMtx.Lock()
+	namedMgrs = make(map[string]*ContextManager)
+	namedMgrsMtx.Unlock()
+	goroutineFlags = newContextManager(Option{})
+	DefaultManager = newDefaultManager()
+
+	stackTagPool = newIDPool()
+	atomic.StoreUint64(&managersRegistered, 0)
+	atomic.StoreUint64(&goCalls, 0)
+	atomic.StoreUint64(&setValuesCalls, 0)
+	atomic.StoreUint64(&extendEvents, 0)
+	SetGoroutineIDFunc(nil)
+	SetGoPrologue(nil)
+	SetGoEpilogue(nil)
+	SetLogf(nil)
+	WarnOnEmptyGo(false)
+}
+
+func TestResetPackageState(t *testing.T) {
+	if !isolated(t) {
+		return
+	}
+
+	// a first test body leaves a registered manager and identifiers behind
+	resetPackageState()
+	leaked := NewContextManager(Option{})
+	for i := 0; i < 3; i++ {
+		if _, err := stackTagPool.Acquire(); err != nil {
+			t.Fatalf("expected an identifier, got %v", err)
+		}
+	}
+
+	// a second one starts from a clean slate
+	resetPackageState()
+	if id, _ := stackTagPool.Acquire(); id != 0 {
+		t.Fatalf("expected identifiers to start from 0, got %d", id)
+	}
+	mgrRegistryMtx.RLock()
+	registered := mgrRegistry[leaked]
+	count := len(mgrRegistry)
+	mgrRegistryMtx.RUnlock()
+	if registered || count != 0 {
+		t.Fatalf("expected no registered managers, got %d", count)
+	}
+}
@@ -0,0 +1,57 @@
+package gls
+
+import (
+	"fmt"
+)
+
+// Scoped bundles a never-before-used key, the type of its values and the
+// ContextManager they live on, so call sites need neither a manager argument
+// nor a type assertion:
+//
+//	var userScope = gls.NewScoped[*User](gls.DefaultManager)
+//
+//	userScope.Set(user, func() {
+//		handle(userScope.Must())
+//	})
+//
+// The zero Scoped is not usable.
+type Scoped[T any] struct {
+	key TypedKey[ContextKey, T]
+	mgr *ContextManager
+}
+
+// NewScoped returns a Scoped storing values of type T on m under a key from
+// GenSym.
+func NewScoped[T any](m *ContextManager) Scoped[T] {
+	return Scoped[T]{key: NewTypedKey[T](GenSym()), mgr: m}
+}
+
+// Set calls fn with s set to value, as SetValues does.
+func (s Scoped[T]) Set(value T, fn func()) {
+	s.key.Set(s.mgr, value, fn)
+}
+
+// Get returns the value set for s on the current goroutine, and whether there
+// is one.
+func (s Scoped[T]) Get() (value T, ok bool) {
+	return s.key.Get(s.mgr)
+}
+
+// GetOr returns the value set for s on the current goroutine, or def if there
+// is none.
+func (s Scoped[T]) GetOr(def T) T {
+	if value, ok := s.Get(); ok {
+		return value
+	}
+	return def
+}
+
+// Must returns the value set for s on the current goroutine, and panics if
+// there is none.
+func (s Scoped[T]) Must() T {
+	value, ok := s.Get()
+	if !ok {
+		panic(fmt.Sprintf("gls: no %T value set for Scoped", value))
+	}
+	return value
+}
@@ -0,0 +1,42 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestScoped(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	user := NewScoped[string](mgr)
+	other := NewScoped[string](mgr)
+
+	if val, ok := user.Get(); ok || val != "" {
+		t.Fatalf("expected no value before Set, got %q, %t", val, ok)
+	}
+	if val := user.GetOr("anonymous"); val != "anonymous" {
+		t.Fatalf("expected the default, got %q", val)
+	}
+
+	user.Set("bob", func() {
+		if val, ok := user.Get(); !ok || val != "bob" {
+			t.Fatalf("expected bob, got %q, %t", val, ok)
+		}
+		if val := user.GetOr("anonymous"); val != "bob" {
+			t.Fatalf("expected bob over the default, got %q", val)
+		}
+		if val := user.Must(); val != "bob" {
+			t.Fatalf("expected bob from Must, got %q", val)
+		}
+		if _, ok := other.Get(); ok {
+			t.Fatalf("expected Scopeds on the same manager not to collide")
+		}
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected Must to panic without a value")
+		}
+	}()
+	user.Must()
+}
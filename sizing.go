@@ -0,0 +1,35 @@
+package gls
+
+// RecommendCapacity returns an Option sized for a process that has been
+// observed to run at most observedPeak goroutines at once, for instance the
+// peak of runtime.NumGoroutine under production load. Goroutine identifiers
+// are reused, so the highest identifier in use tracks that peak.
+//
+// The initial capacity leaves 25% headroom above observedPeak, rounded up to
+// a multiple of 64, so steady-state traffic never extends. The extend unit is
+// an eighth of that, between 16 and 4096, so a burst past the headroom
+// extends a few times rather than many. The defaults correspond to a peak of
+// about 800 goroutines. A non-positive observedPeak returns the zero Option,
+// which selects the defaults.
+//
+// Only InitialMaxGoroutineCount and ExtendUnit are set; fill in any other
+// fields on the result before passing it to NewContextManager.
+func RecommendCapacity(observedPeak int) Option {
+	if observedPeak <= 0 {
+		return Option{}
+	}
+	initial := roundUp(observedPeak+(observedPeak+3)/4, 64)
+	unit := roundUp(initial/8, 16)
+	if unit > 4096 {
+		unit = 4096
+	}
+	return Option{InitialMaxGoroutineCount: initial, ExtendUnit: unit}
+}
+
+// roundUp rounds n up to a multiple of unit, treating n < 1 as 1.
+func roundUp(n, unit int) int {
+	if n < 1 {
+		n = 1
+	}
+	return (n + unit - 1) / unit * unit
+}
@@ -0,0 +1,36 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestRecommendCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		peak, initial, unit int
+	}{
+		{0, 0, 0},
+		{-5, 0, 0},
+		{1, 64, 16},
+		{50, 64, 16},
+		{52, 128, 16},
+		{1000, 1280, 160},
+		{10000, 12544, 1568},
+		{1000000, 1250048, 4096},
+	} {
+		opt := RecommendCapacity(tc.peak)
+		if opt.InitialMaxGoroutineCount != tc.initial || opt.ExtendUnit != tc.unit {
+			t.Fatalf("expected initial %d and unit %d for peak %d, got %d and %d",
+				tc.initial, tc.unit, tc.peak, opt.InitialMaxGoroutineCount,
+				opt.ExtendUnit)
+		}
+		if tc.peak > 0 && opt.InitialMaxGoroutineCount < tc.peak {
+			t.Fatalf("expected initial capacity to cover peak %d, got %d",
+				tc.peak, opt.InitialMaxGoroutineCount)
+		}
+	}
+
+	if opt := RecommendCapacity(819); opt.InitialMaxGoroutineCount !=
+		initialMaxGoroutineCount || opt.ExtendUnit != extendUnit {
+		t.Fatalf("expected defaults to match a peak of 819, got %+v", opt)
+	}
+}
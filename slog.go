@@ -0,0 +1,29 @@
+//go:build go1.21
+// +build go1.21
+
+package gls
+
+import (
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// SetLogger calls fn with l set as the current logger on m, so that code
+// further down the stack can get it with Logger instead of having it passed
+// in. Like any other value, the logger is propagated by Go; children share the
+// same *slog.Logger, which is safe for concurrent use.
+func SetLogger(m *ContextManager, l *slog.Logger, fn func()) {
+	m.SetValues(Values{loggerKey{}: l}, fn)
+}
+
+// Logger returns the logger set on m with SetLogger, or slog.Default() if
+// there isn't one.
+func Logger(m *ContextManager) *slog.Logger {
+	if l, ok := m.GetValue(loggerKey{}); ok {
+		if l, ok := l.(*slog.Logger); ok && l != nil {
+			return l
+		}
+	}
+	return slog.Default()
+}
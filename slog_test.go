@@ -0,0 +1,41 @@
+//go:build go1.21
+// +build go1.21
+
+package gls
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	if Logger(mgr) != slog.Default() {
+		t.Fatalf("expected default logger when none is set")
+	}
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, nil)).With("request", "1234")
+	SetLogger(mgr, l, func() {
+		if Logger(mgr) != l {
+			t.Fatalf("expected the logger that was set")
+		}
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			Logger(mgr).Info("from child")
+		})
+		<-done
+	})
+	if !strings.Contains(buf.String(), "request=1234") {
+		t.Fatalf("expected child to log through the propagated logger, got %q",
+			buf.String())
+	}
+	if Logger(mgr) != slog.Default() {
+		t.Fatalf("expected default logger after scope exits")
+	}
+}
@@ -0,0 +1,45 @@
+package gls
+
+import (
+	"sync/atomic"
+)
+
+// counters are kept with atomics on the paths they count, for ReadStats.
+var (
+	managersRegistered uint64
+	goCalls            uint64
+	setValuesCalls     uint64
+	extendEvents       uint64
+)
+
+// Stats describes this package's activity since startup, as returned by
+// ReadStats.
+type Stats struct {
+	// Managers counts the ContextManagers registered, whether or not they
+	// have been unregistered since.
+	Managers uint64
+	// GoCalls counts the goroutines started by Go and the functions built
+	// on it, such as GoSeeded and GoSlice.
+	GoCalls uint64
+	// SetValuesCalls counts the SetValues calls that set at least one
+	// value.
+	SetValuesCalls uint64
+	// MaxGoroutineID is the number of goroutine identifiers handed out so
+	// far, the high-water mark of concurrently tagged goroutines.
+	MaxGoroutineID uint64
+	// Extends counts the times a ContextManager grew its storage.
+	Extends uint64
+}
+
+// ReadStats returns the package's activity counters, for exporting to a
+// metrics system; the glsexpvar package publishes them through expvar. Each
+// counter is read atomically, but not all of them at the same instant.
+func ReadStats() Stats {
+	return Stats{
+		Managers:       atomic.LoadUint64(&managersRegistered),
+		GoCalls:        atomic.LoadUint64(&goCalls),
+		SetValuesCalls: atomic.LoadUint64(&setValuesCalls),
+		MaxGoroutineID: uint64(atomic.LoadUint32(&stackTagPool.curID)),
+		Extends:        atomic.LoadUint64(&extendEvents),
+	}
+}
@@ -0,0 +1,32 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestReadStats(t *testing.T) {
+	before := ReadStats()
+	mgr := NewContextManager(Option{InitialMaxGoroutineCount: 1, ExtendUnit: 1})
+	defer mgr.Unregister()
+	mgr.extend(2)
+	mgr.SetValues(Values{"key": "val"}, func() {
+		done := make(chan struct{})
+		Go(func() { close(done) })
+		<-done
+	})
+	after := ReadStats()
+
+	for name, delta := range map[string]uint64{
+		"Managers":       after.Managers - before.Managers,
+		"GoCalls":        after.GoCalls - before.GoCalls,
+		"SetValuesCalls": after.SetValuesCalls - before.SetValuesCalls,
+		"Extends":        after.Extends - before.Extends,
+	} {
+		if delta < 1 {
+			t.Fatalf("expected %s to grow, got %d", name, delta)
+		}
+	}
+	if after.MaxGoroutineID == 0 {
+		t.Fatalf("expected identifiers to have been handed out")
+	}
+}
@@ -0,0 +1,196 @@
+package gls
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Scope calls fn with v set, like SetValues, and additionally hands fn a
+// context.Context derived from parent whose Value method returns the same
+// values as GetValue does at the start of fn, including v. This lets code
+// mid-migration between gls and context.Context use either mechanism, and pass
+// ctx on to code that only understands the latter.
+//
+// ctx is a snapshot taken at scope entry. Values set by nested SetValues calls
+// within fn are visible through GetValue but not through ctx. A nil v sets
+// nothing, and ctx then only carries values that were already set.
+func (m *ContextManager) Scope(parent context.Context, v Values,
+	fn func(ctx context.Context)) {
+	m.SetValues(v, func() {
+		var values Values
+		if gid, ok := GetGoroutineId(); ok {
+			values = m.rawSnapshot(gid)
+		}
+		fn(WithValues(parent, values))
+	})
+}
+
+// GoUnlessCancelled is like Go, except that it does nothing if ctx is already
+// done, saving the cost of capturing values and starting a goroutine for work
+// that is no longer wanted. It reports whether cb was started.
+func GoUnlessCancelled(ctx context.Context, cb func()) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	Go(cb)
+	return true
+}
+
+// GoWithTimeout is like Go, but hands cb a context.Context that times out d
+// after GoWithTimeout is called, and is cancelled as soon as cb returns. It
+// is up to cb to watch the context and give up once it is done; a cb that
+// ignores it runs for as long as it likes, and the context only signals that
+// it should have stopped.
+func GoWithTimeout(d time.Duration, cb func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	Go(func() {
+		defer cancel()
+		cb(ctx)
+	})
+}
+
+// GoContextN calls worker n times, with i running from 0 to n-1, each call in
+// its own goroutine started like Go would, at most limit at a time, and waits
+// for all started calls to return. A limit less than 1 is treated as 1. The
+// current context is captured once, up front, and every worker sees it.
+//
+// Workers receive a child of ctx that is cancelled as soon as any worker
+// returns an error or ctx itself is done. Once that happens no further
+// workers are started, and GoContextN returns the first error returned by a
+// worker or, if none failed, ctx's error if ctx being done kept a worker from
+// starting; a ctx done only after every worker started is not reported.
+// Workers already running are not interrupted beyond the cancellation of
+// their context, so long-running workers should watch it.
+func GoContextN(ctx context.Context, n, limit int,
+	worker func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+		stopped  bool
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	apply := captureForGo()
+	sem := make(chan struct{}, limit)
+spawn:
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-workerCtx.Done():
+			stopped = true
+			break spawn
+		}
+		// select picks at random when both cases are ready
+		if workerCtx.Err() != nil {
+			stopped = true
+			break
+		}
+		wg.Add(1)
+		cb := func(i int) func() {
+			return func() {
+				defer func() { <-sem }()
+				defer wg.Done()
+				if err := worker(workerCtx, i); err != nil {
+					fail(err)
+				}
+			}
+		}(i)
+		go apply(withGoHooks(cb))
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if stopped {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// WithValue is a drop-in for context.WithValue for code migrating between
+// context.Context and gls. Besides returning a child of parent carrying the
+// value, it writes the value into DefaultManager for the current goroutine, so
+// code further down the stack that reads DefaultManager rather than receiving
+// the context finds it too.
+//
+// The gls write only happens within a DefaultManager.SetValues scope (for
+// instance one opened by request middleware), as there would otherwise be
+// nothing to clean the value up. It changes that scope's values in place
+// rather than opening a new scope: the value stays visible until the
+// outermost DefaultManager scope on the goroutine exits, or, if an enclosing
+// scope had set key itself, until that scope restores its previous value. A
+// key pinned with Pin is left as it is, or panics, as SetValues would.
+func WithValue(parent context.Context, key, value interface{}) context.Context {
+	DefaultManager.setCurrent(key, value)
+	return context.WithValue(parent, key, value)
+}
+
+// GetValueContext looks key up in both gls and ctx, for code migrating
+// between the two, where a value may have been set by either depending on
+// which layer set it. A value set on this ContextManager wins; otherwise
+// ctx.Value(key) is used if it isn't nil, and only then are the defaults
+// GetValue would return considered. ctx may be nil.
+func (m *ContextManager) GetValueContext(ctx context.Context,
+	key interface{}) (value interface{}, ok bool) {
+	if value, ok = m.lookup(key); ok {
+		return value, true
+	}
+	if ctx != nil {
+		if value = ctx.Value(key); value != nil {
+			return value, true
+		}
+	}
+	return m.missing(key)
+}
+
+type valuesContext struct {
+	context.Context
+	values Values
+}
+
+// WithValues returns a copy of parent whose Value method looks keys up in v
+// before deferring to parent. v is copied, so later changes to it are not
+// reflected, and may be nil, in which case every lookup goes to parent.
+// Combined with Snapshot, this hands a frozen view of a ContextManager's
+// values to libraries that only accept a context.Context:
+//
+//	lib.Do(gls.WithValues(ctx, mgr.Snapshot()))
+func WithValues(parent context.Context, v Values) context.Context {
+	values := make(Values, len(v))
+	for key, val := range v {
+		values[key] = val
+	}
+	return &valuesContext{Context: parent, values: values}
+}
+
+func (c *valuesContext) Value(key interface{}) interface{} {
+	if val, ok := c.values[key]; ok {
+		switch v := val.(type) {
+		case *lazyValue:
+			return v.get()
+		case *sharedValue:
+			return v.value
+		case *compressedValue:
+			if data, ok := v.bytes(); ok {
+				return data
+			}
+			return c.Context.Value(key)
+		}
+		return val
+	}
+	return c.Context.Value(key)
+}
@@ -0,0 +1,317 @@
+package gls
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stdContextKey string
+
+func TestWithValues(t *testing.T) {
+	parent := context.WithValue(context.Background(), stdContextKey("a"), "parent")
+	parent = context.WithValue(parent, stdContextKey("b"), "parent")
+
+	v := Values{stdContextKey("b"): "values", stdContextKey("c"): "values"}
+	ctx := WithValues(parent, v)
+	v[stdContextKey("c")] = "changed"
+
+	for key, exp := range map[stdContextKey]interface{}{
+		"a": "parent", "b": "values", "c": "values", "d": nil} {
+		if val := ctx.Value(key); val != exp {
+			t.Fatalf("expected value %v for key %s, got %v", exp, key, val)
+		}
+	}
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	ctx = WithValues(cancelCtx, nil)
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected cancellation to come from parent, got %v", ctx.Err())
+	}
+}
+
+func TestWithValuesSnapshot(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var ctx context.Context
+	mgr.SetValues(Values{"key": "val"}, func() {
+		ctx = WithValues(context.Background(), mgr.Snapshot())
+	})
+	if val := ctx.Value("key"); val != "val" {
+		t.Fatalf("expected snapshot value val, got %v", val)
+	}
+}
+
+func TestScope(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	parent := context.WithValue(context.Background(), stdContextKey("p"), "parent")
+	mgr.SetValues(Values{stdContextKey("outer"): "outer"}, func() {
+		mgr.Scope(parent, Values{stdContextKey("k"): "val"}, func(ctx context.Context) {
+			for key, exp := range map[stdContextKey]string{
+				"p": "parent", "k": "val", "outer": "outer"} {
+				if val := ctx.Value(key); val != exp {
+					t.Fatalf("expected ctx value %s for key %s, got %v", exp, key, val)
+				}
+			}
+			if val, _ := mgr.GetValue(stdContextKey("k")); val != "val" {
+				t.Fatalf("expected gls value val, got %v", val)
+			}
+			mgr.SetValues(Values{stdContextKey("k"): "nested"}, func() {
+				if val := ctx.Value(stdContextKey("k")); val != "val" {
+					t.Fatalf("expected ctx to keep its entry snapshot, got %v", val)
+				}
+			})
+		})
+		if _, ok := mgr.GetValue(stdContextKey("k")); ok {
+			t.Fatalf("expected gls value to be restored after Scope")
+		}
+	})
+}
+
+func TestGoUnlessCancelled(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mgr.SetValues(Values{"key": "val"}, func() {
+		done := make(chan interface{})
+		if !GoUnlessCancelled(ctx, func() {
+			val, _ := mgr.GetValue("key")
+			done <- val
+		}) {
+			t.Fatalf("expected live context to start the goroutine")
+		}
+		if val := <-done; val != "val" {
+			t.Fatalf("expected propagated value val, got %v", val)
+		}
+
+		cancel()
+		if GoUnlessCancelled(ctx, func() {
+			t.Errorf("expected cancelled context not to start the goroutine")
+		}) {
+			t.Fatalf("expected GoUnlessCancelled to report false")
+		}
+	})
+}
+
+func TestGoWithTimeout(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	contexts := make(chan context.Context)
+	mgr.SetValues(Values{"key": "val"}, func() {
+		start := time.Now()
+		GoWithTimeout(time.Hour, func(ctx context.Context) {
+			if val, _ := mgr.GetValue("key"); val != "val" {
+				t.Errorf("expected propagated value val, got %v", val)
+			}
+			deadline, ok := ctx.Deadline()
+			if !ok || deadline.Before(start.Add(time.Hour)) ||
+				deadline.After(time.Now().Add(time.Hour)) {
+				t.Errorf("expected a deadline an hour out, got %v", deadline)
+			}
+			contexts <- ctx
+		})
+	})
+	ctx := <-contexts
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected context to be cancelled once cb returned")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", ctx.Err())
+	}
+
+	GoWithTimeout(time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+		contexts <- ctx
+	})
+	if err := (<-contexts).Err(); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGoContextN(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	parent := context.WithValue(context.Background(), stdContextKey("p"), "parent")
+	var (
+		mtx     sync.Mutex
+		seen    = make(map[int]bool)
+		running int32
+	)
+	mgr.SetValues(Values{"key": "val"}, func() {
+		err := GoContextN(parent, 20, 3, func(ctx context.Context, i int) error {
+			defer atomic.AddInt32(&running, -1)
+			if n := atomic.AddInt32(&running, 1); n > 3 {
+				t.Errorf("expected at most 3 concurrent workers, got %d", n)
+			}
+			if val, _ := mgr.GetValue("key"); val != "val" {
+				t.Errorf("expected gls value val in worker %d, got %v", i, val)
+			}
+			if val := ctx.Value(stdContextKey("p")); val != "parent" {
+				t.Errorf("expected ctx value parent in worker %d, got %v", i, val)
+			}
+			mtx.Lock()
+			seen[i] = true
+			mtx.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+	if len(seen) != 20 {
+		t.Fatalf("expected 20 workers to run, got %d", len(seen))
+	}
+}
+
+func TestGoContextNError(t *testing.T) {
+	failure := errors.New("failure")
+	var started int32
+	err := GoContextN(context.Background(), 100, 1, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&started, 1)
+		if i == 2 {
+			return failure
+		}
+		if i > 2 && ctx.Err() == nil {
+			t.Errorf("expected worker %d to see a cancelled context", i)
+		}
+		return ctx.Err()
+	})
+	if err != failure {
+		t.Fatalf("expected first error to be returned, got %v", err)
+	}
+	if n := atomic.LoadInt32(&started); n != 3 {
+		t.Fatalf("expected no workers to start after the failure, got %d started", n)
+	}
+}
+
+func TestGoContextNCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var started int32
+	err := GoContextN(ctx, 100, 2, func(ctx context.Context, i int) error {
+		if atomic.AddInt32(&started, 1) == 1 {
+			cancel()
+		}
+		<-ctx.Done()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := atomic.LoadInt32(&started); n > 2 {
+		t.Fatalf("expected no workers to start after cancellation, got %d started", n)
+	}
+
+	// cancelled only once every worker has started
+	ctx, cancel = context.WithCancel(context.Background())
+	err = GoContextN(ctx, 3, 1, func(ctx context.Context, i int) error {
+		if i == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error when every worker ran, got %v", err)
+	}
+}
+
+func TestWithValue(t *testing.T) {
+	key := stdContextKey("user")
+
+	ctx := WithValue(context.Background(), key, "nobody")
+	if val := ctx.Value(key); val != "nobody" {
+		t.Fatalf("expected context value without a scope, got %v", val)
+	}
+	if _, ok := DefaultManager.GetValue(key); ok {
+		t.Fatalf("expected no gls value without a scope")
+	}
+
+	DefaultManager.SetValues(Values{"request": "1"}, func() {
+		func() {
+			ctx = WithValue(context.Background(), key, "bob")
+		}()
+		if val := ctx.Value(key); val != "bob" {
+			t.Fatalf("expected context value bob, got %v", val)
+		}
+		if val, _ := DefaultManager.GetValue(key); val != "bob" {
+			t.Fatalf("expected gls value bob, got %v", val)
+		}
+
+		done := make(chan interface{})
+		Go(func() {
+			val, _ := DefaultManager.GetValue(key)
+			done <- val
+		})
+		if val := <-done; val != "bob" {
+			t.Fatalf("expected gls value in child goroutine, got %v", val)
+		}
+	})
+	if _, ok := DefaultManager.GetValue(key); ok {
+		t.Fatalf("expected gls value to be cleaned up with the scope")
+	}
+}
+
+func TestDefaultManagerRegistersOnUse(t *testing.T) {
+	if !isolated(t) {
+		return
+	}
+
+	registered := func() bool {
+		mgrRegistryMtx.RLock()
+		defer mgrRegistryMtx.RUnlock()
+		return mgrRegistry[DefaultManager]
+	}
+	if registered() {
+		t.Fatalf("expected DefaultManager not to be registered before use")
+	}
+	DefaultManager.SetValues(Values{"request": "1"}, func() {
+		if !registered() {
+			t.Fatalf("expected DefaultManager to register on first use")
+		}
+	})
+
+	DefaultManager.Unregister()
+	DefaultManager.SetValues(Values{"request": "2"}, func() {})
+	if registered() {
+		t.Fatalf("expected an unregistered DefaultManager to stay unregistered")
+	}
+}
+
+func TestGetValueContext(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+	key := stdContextKey("user")
+
+	check := func(ctx context.Context, expected interface{}, expectedOk bool) {
+		t.Helper()
+		val, ok := mgr.GetValueContext(ctx, key)
+		if val != expected || ok != expectedOk {
+			t.Fatalf("expected %v, %t, got %v, %t", expected, expectedOk, val, ok)
+		}
+	}
+
+	withCtx := context.WithValue(context.Background(), key, "ctx")
+	check(context.Background(), nil, false)
+	check(nil, nil, false)
+	check(withCtx, "ctx", true)
+	mgr.SetValues(Values{key: "gls"}, func() {
+		check(context.Background(), "gls", true)
+		check(withCtx, "gls", true)
+	})
+
+	// a value in ctx beats a default
+	mgr.SetDefaults(Values{key: "default"})
+	check(withCtx, "ctx", true)
+	check(context.Background(), "default", true)
+}
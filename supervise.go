@@ -0,0 +1,27 @@
+package gls
+
+// Supervise runs cb with panics recovered, and returns a restart function
+// that runs cb again the same way, for supervisors that restart work after it
+// crashes. Both run cb with the values every registered ContextManager had
+// when Supervise was called, taken the same way Go takes them, so restarted
+// work carries its original context. restart may be called any number of
+// times, from any goroutine, such as a new one started with the go keyword;
+// the captured values are frozen at the Supervise call, and changes made
+// since, or by earlier runs, are not seen.
+//
+// A recovered panic is reported through the function set with SetLogf and
+// otherwise dropped. cb needs to record its own progress, if it has any, for
+// the supervisor to decide whether to restart it.
+func Supervise(cb func()) (restart func()) {
+	apply := capture()
+	restart = func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logf("gls: supervised callback panicked: %v", r)
+			}
+		}()
+		apply(cb)
+	}
+	restart()
+	return restart
+}
@@ -0,0 +1,49 @@
+package gls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSupervise(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	stop := captureLogs()
+	var (
+		runs     int
+		requests []interface{}
+		restart  func()
+	)
+	mgr.SetValues(Values{"request": "1"}, func() {
+		restart = Supervise(func() {
+			runs++
+			request, _ := mgr.GetValue("request")
+			requests = append(requests, request)
+			if runs == 1 {
+				panic("crashed")
+			}
+		})
+	})
+	logs := stop()
+	if len(logs) != 1 || !strings.Contains(logs[0], "crashed") {
+		t.Fatalf("expected the panic to be logged, got %v", logs)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		restart()
+	}()
+	<-done
+	mgr.SetValues(Values{"request": "2"}, restart)
+
+	if runs != 3 {
+		t.Fatalf("expected 3 runs, got %d", runs)
+	}
+	for _, request := range requests {
+		if request != "1" {
+			t.Fatalf("expected every run to see request 1, got %v", requests)
+		}
+	}
+}
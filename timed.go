@@ -0,0 +1,30 @@
+package gls
+
+import (
+	"time"
+)
+
+// SetValuesTimed is like SetValues, but also times fn and returns how long it
+// ran, and reports the keys and duration to the Option.ScopeTimer, if any,
+// for attributing latency to the scopes a request passes through. The timer
+// is called while v is still set. If fn panics, the ScopeTimer is still
+// called, with the time up to the panic, before the panic continues.
+func (m *ContextManager) SetValuesTimed(v Values, fn func()) (took time.Duration) {
+	m.SetValues(v, func() {
+		start := time.Now()
+		defer func() {
+			took = time.Since(start)
+			if m.scopeTimer == nil {
+				return
+			}
+			keys := make([]interface{}, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sortKeys(keys, nil)
+			m.scopeTimer(keys, took)
+		}()
+		fn()
+	})
+	return took
+}
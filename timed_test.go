@@ -0,0 +1,63 @@
+package gls
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetValuesTimed(t *testing.T) {
+	var (
+		timedKeys []interface{}
+		timedTook time.Duration
+		inScope   bool
+	)
+	var mgr *ContextManager
+	mgr = NewContextManager(Option{
+		ScopeTimer: func(keys []interface{}, took time.Duration) {
+			timedKeys, timedTook = keys, took
+			_, inScope = mgr.GetValue("phase")
+		},
+	})
+	defer mgr.Unregister()
+
+	took := mgr.SetValuesTimed(Values{"phase": "parse", "id": 1}, func() {
+		time.Sleep(10 * time.Millisecond)
+	})
+	if took < 10*time.Millisecond {
+		t.Fatalf("expected at least 10ms, got %v", took)
+	}
+	if timedTook != took {
+		t.Fatalf("expected the timer to get %v, got %v", took, timedTook)
+	}
+	if len(timedKeys) != 2 || timedKeys[0] != "id" || timedKeys[1] != "phase" {
+		t.Fatalf("expected sorted keys [id phase], got %v", timedKeys)
+	}
+	if !inScope {
+		t.Fatalf("expected the timer to run while values are set")
+	}
+}
+
+func TestSetValuesTimedPanic(t *testing.T) {
+	var timedTook time.Duration
+	mgr := NewContextManager(Option{
+		ScopeTimer: func(keys []interface{}, took time.Duration) {
+			timedTook = took
+		},
+	})
+	defer mgr.Unregister()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected panic boom, got %v", r)
+			}
+		}()
+		mgr.SetValuesTimed(Values{"phase": "parse"}, func() {
+			time.Sleep(10 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	if timedTook < 10*time.Millisecond {
+		t.Fatalf("expected the time up to the panic, got %v", timedTook)
+	}
+}
@@ -0,0 +1,47 @@
+package gls
+
+// Token is an opaque handle to the values a ContextManager had on some
+// goroutine at some point, obtained with Capture and applied with
+// RunWithToken. The zero Token holds no values.
+type Token struct {
+	values Values
+}
+
+// Capture returns a Token holding a copy of the current goroutine's values.
+// The Token remains valid after the capturing goroutine's scopes exit, or the
+// goroutine itself ends, and may be applied any number of times from any
+// goroutine. It is useful for handing a task's originating context to
+// whichever worker eventually runs it.
+func (m *ContextManager) Capture() Token {
+	gid, ok := GetGoroutineId()
+	if !ok {
+		return Token{}
+	}
+	return Token{values: m.propagate(m.rawSnapshot(gid))}
+}
+
+// RunWithToken calls fn with the values held by t set, in the same way as
+// SetValues. Values already set on the current goroutine that t does not
+// override remain visible. A Token holding no values, such as the zero Token,
+// sets nothing, and fn is simply called.
+func (m *ContextManager) RunWithToken(t Token, fn func()) {
+	m.SetValues(t.values, fn)
+}
+
+// Attach calls fn with the values in snapshot set, in the same way as
+// SetValues, on whichever goroutine calls it. It is the consumer half of
+// handing work to long-lived goroutines that were started before the work's
+// values existed, such as the workers of a pool: the producer takes a
+// Snapshot and sends it along with the job, and the worker runs the job
+// within Attach, so that the job sees the producer's values and the worker
+// is back to its own once the job is done. See the example.
+//
+// snapshot is not modified. Values implementing Cloner are copied on write
+// by the worker, as for Go.
+func (m *ContextManager) Attach(snapshot Values, fn func()) {
+	values := make(Values, len(snapshot))
+	for key, val := range snapshot {
+		values[key] = val
+	}
+	m.SetValues(m.propagate(values), fn)
+}
@@ -0,0 +1,83 @@
+package gls
+
+import (
+	"testing"
+)
+
+func TestCaptureToken(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	tokens := make(chan Token)
+	go func() {
+		mgr.SetValues(Values{"creator": "task1"}, func() {
+			tokens <- mgr.Capture()
+		})
+	}()
+	token := <-tokens
+
+	results := make(chan interface{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			mgr.RunWithToken(token, func() {
+				val, _ := mgr.GetValue("creator")
+				results <- val
+			})
+		}()
+		if val := <-results; val != "task1" {
+			t.Fatalf("expected creator task1, got %v", val)
+		}
+	}
+
+	mgr.SetValues(Values{"creator": "worker", "other": "val"}, func() {
+		mgr.RunWithToken(token, func() {
+			if val, _ := mgr.GetValue("creator"); val != "task1" {
+				t.Fatalf("expected token value to win, got %v", val)
+			}
+			if val, _ := mgr.GetValue("other"); val != "val" {
+				t.Fatalf("expected existing value to remain, got %v", val)
+			}
+		})
+	})
+
+	called := false
+	mgr.RunWithToken(Token{}, func() { called = true })
+	if !called {
+		t.Fatalf("expected zero Token to still call fn")
+	}
+}
+
+func TestAttach(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	type job struct {
+		snapshot Values
+		done     chan interface{}
+	}
+	jobs := make(chan job)
+	// started before any request exists, so it has nothing to inherit
+	go mgr.SetValues(Values{"worker": 1}, func() {
+		for j := range jobs {
+			mgr.Attach(j.snapshot, func() {
+				request, _ := mgr.GetValue("request")
+				worker, _ := mgr.GetValue("worker")
+				j.done <- [2]interface{}{request, worker}
+			})
+			if _, ok := mgr.GetValue("request"); ok {
+				t.Errorf("expected the job's values to be gone after Attach")
+			}
+		}
+	})
+
+	for _, request := range []string{"first", "second"} {
+		done := make(chan interface{})
+		mgr.SetValues(Values{"request": request}, func() {
+			jobs <- job{snapshot: mgr.Snapshot(), done: done}
+		})
+		if got := <-done; got != [2]interface{}{request, 1} {
+			t.Fatalf("expected %s on worker 1, got %v", request, got)
+		}
+	}
+	close(jobs)
+}
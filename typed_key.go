@@ -0,0 +1,45 @@
+package gls
+
+// TypedKey is a key whose values all have type V, for code that would rather
+// use context.Context-style keys than GenSym. Any comparable value can key a
+// ContextManager, and the idiomatic way to get one no other package can
+// collide with is an unexported type:
+//
+//	type userKey struct{}
+//
+//	var UserKey = gls.NewTypedKey[*User](userKey{})
+//
+// Values set through UserKey are stored under userKey{}, so GetValue and
+// SetValues see them under that key too. Two keys of different types never
+// collide, even if the types have the same name and shape, so a userKey
+// declared in another package is a different key.
+type TypedKey[K comparable, V any] struct {
+	key K
+}
+
+// NewTypedKey returns a TypedKey storing values of type V under key. The zero
+// TypedKey uses the zero value of K.
+func NewTypedKey[V any, K comparable](key K) TypedKey[K, V] {
+	return TypedKey[K, V]{key: key}
+}
+
+// Key returns the key values are stored under.
+func (k TypedKey[K, V]) Key() K {
+	return k.key
+}
+
+// Get returns the value set for k on the current goroutine. ok is false if it
+// isn't set, or was set to something other than a V through SetValues.
+func (k TypedKey[K, V]) Get(m *ContextManager) (value V, ok bool) {
+	raw, found := m.GetValue(k.key)
+	if !found {
+		return value, false
+	}
+	value, ok = raw.(V)
+	return value, ok
+}
+
+// Set calls fn with k set to value on m, as SetValues does.
+func (k TypedKey[K, V]) Set(m *ContextManager, value V, fn func()) {
+	m.SetValues(Values{k.key: value}, fn)
+}
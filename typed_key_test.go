@@ -0,0 +1,57 @@
+package gls
+
+import (
+	"testing"
+)
+
+type userKey struct{}
+
+func TestTypedKey(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	key := NewTypedKey[string](userKey{})
+	if _, ok := key.Get(mgr); ok {
+		t.Fatalf("expected no value before Set")
+	}
+	key.Set(mgr, "bob", func() {
+		if val, ok := key.Get(mgr); !ok || val != "bob" {
+			t.Fatalf("expected bob, got %v, %t", val, ok)
+		}
+		if val, _ := mgr.GetValue(userKey{}); val != "bob" {
+			t.Fatalf("expected bob under the plain key, got %v", val)
+		}
+		var zero TypedKey[userKey, string]
+		if val, _ := zero.Get(mgr); val != "bob" {
+			t.Fatalf("expected the zero key to match, got %v", val)
+		}
+	})
+
+	mgr.SetValues(Values{userKey{}: 42}, func() {
+		if val, ok := key.Get(mgr); ok {
+			t.Fatalf("expected a value of the wrong type to be missing, got %v", val)
+		}
+	})
+}
+
+func TestTypedKeyNoCollision(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	outer := NewTypedKey[string](userKey{})
+	// same name and shape as the package-level userKey, as another package's
+	// key would be, but a different type
+	type userKey struct{}
+	inner := NewTypedKey[string](userKey{})
+
+	outer.Set(mgr, "outer", func() {
+		if val, ok := inner.Get(mgr); ok {
+			t.Fatalf("expected keys not to collide, got %v", val)
+		}
+		inner.Set(mgr, "inner", func() {
+			if val, _ := outer.Get(mgr); val != "outer" {
+				t.Fatalf("expected outer, got %v", val)
+			}
+		})
+	})
+}
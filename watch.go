@@ -0,0 +1,92 @@
+package gls
+
+// Watch registers fn to be called whenever SetValues changes key's value on
+// the current goroutine: when a scope sets or shadows it, and when the scope
+// exits and its previous value is restored. old and new are nil when key was
+// or becomes unset, and a value set by SetLazy that has not been computed yet
+// is reported as nil. fn is called on the current goroutine, without any
+// locks held, before the scope's callback runs or after its values are
+// restored. Setting key to a value equal to its current one does not call
+// fn. Changes made without SetValues, such as through WithValue or
+// SwapValues, are not reported.
+//
+// Watchers are goroutine-local and are not inherited by goroutines started
+// with Go. They are dropped, without being called, when the goroutine's
+// outermost scope exits. Watch does nothing if the current goroutine has no
+// values on this ContextManager. Only keys with watchers cost anything extra
+// on SetValues.
+func (m *ContextManager) Watch(key interface{}, fn func(old, new interface{})) {
+	meta := m.currentMeta(true)
+	if meta == nil {
+		return
+	}
+	if meta.watchers == nil {
+		meta.watchers = make(map[interface{}][]func(old, new interface{}))
+	}
+	key = m.normalizeKey(key)
+	meta.watchers[key] = append(meta.watchers[key], fn)
+}
+
+type watchEvent struct {
+	fns      []func(old, new interface{})
+	old, new interface{}
+}
+
+// watchEvents returns the changes to watched keys made by setting or, if
+// restoring, by restoring a scope that added the keys in added and shadowed
+// those in shadowed, with state holding the scope's values. It must be called
+// with extendLock held.
+func (m *ContextManager) watchEvents(gid uint32, state Values,
+	added []interface{}, shadowed []restoreEntry, restoring bool) []watchEvent {
+	meta := m.meta[gid]
+	if meta == nil || len(meta.watchers) == 0 {
+		return nil
+	}
+	var events []watchEvent
+	event := func(key, before interface{}) {
+		if fns := meta.watchers[key]; fns != nil {
+			old, new := before, state[key]
+			if restoring {
+				old, new = new, old
+			}
+			events = append(events, watchEvent{fns: fns, old: old, new: new})
+		}
+	}
+	for _, key := range added {
+		event(key, nil)
+	}
+	for _, entry := range shadowed {
+		event(entry.key, entry.value)
+	}
+	return events
+}
+
+func fireWatchers(events []watchEvent) {
+	for _, event := range events {
+		old, new := peekValue(event.old), peekValue(event.new)
+		if sameValue(old, new) {
+			continue
+		}
+		for _, fn := range event.fns {
+			fn(old, new)
+		}
+	}
+}
+
+// peekValue returns what a stored value stands for, without computing
+// values set by SetLazy, which it returns as nil until they are computed.
+func peekValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *lazyValue:
+		value, _ := v.peek()
+		return value
+	case *sharedValue:
+		return v.value
+	case *compressedValue:
+		if data, ok := v.bytes(); ok {
+			return data
+		}
+		return nil
+	}
+	return value
+}
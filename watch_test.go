@@ -0,0 +1,59 @@
+package gls
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var changes []string
+	watch := func(old, new interface{}) {
+		changes = append(changes, fmt.Sprintf("%v->%v", old, new))
+	}
+
+	mgr.Watch("phase", watch)
+	mgr.SetValues(Values{"request": "1"}, func() {
+		mgr.Watch("phase", watch)
+		mgr.SetValues(Values{"phase": "parse"}, func() {
+			mgr.SetValues(Values{"phase": "exec", "other": 1}, func() {
+				// unchanged values aren't reported
+				mgr.SetValues(Values{"phase": "exec"}, func() {})
+			})
+		})
+	})
+
+	expected := []string{
+		"<nil>->parse", "parse->exec", "exec->parse", "parse-><nil>"}
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Fatalf("expected changes %v, got %v", expected, changes)
+	}
+
+	// dropped along with the goroutine's values
+	changes = nil
+	mgr.SetValues(Values{"phase": "parse"}, func() {})
+	if len(changes) != 0 {
+		t.Fatalf("expected no watchers after release, got %v", changes)
+	}
+}
+
+func TestWatchNotInherited(t *testing.T) {
+	mgr := NewContextManager(Option{})
+	defer mgr.Unregister()
+
+	var calls int
+	mgr.SetValues(Values{"phase": "parse"}, func() {
+		mgr.Watch("phase", func(old, new interface{}) { calls++ })
+		done := make(chan struct{})
+		Go(func() {
+			defer close(done)
+			mgr.SetValues(Values{"phase": "exec"}, func() {})
+		})
+		<-done
+	})
+	if calls != 0 {
+		t.Fatalf("expected watcher not to follow Go, got %d calls", calls)
+	}
+}